@@ -0,0 +1,202 @@
+package logm
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DropPolicy defines the behavior of an AsyncWriter when its queue is full.
+type DropPolicy uint8
+
+// List of supported drop policies.
+const (
+	// Block waits for space in the queue. It's the default policy, no record is lost.
+	Block DropPolicy = iota
+	// DropOldest drops the oldest record of the queue to make space for the new one.
+	DropOldest
+	// DropNewest drops the new record.
+	DropNewest
+)
+
+// NewAsyncWriter returns an AsyncWriter writing asynchronously on w, like a File.
+// The queue is bounded to size records and the policy defines the behavior when it is full.
+// The records are buffered and flushed on w every flushInterval. If flushInterval is zero or negative,
+// they're flushed as soon as the queue is empty.
+// The AsyncWriter must be closed to write the pending records.
+func NewAsyncWriter(w io.Writer, size int, policy DropPolicy, flushInterval time.Duration) *AsyncWriter {
+	if size < 1 {
+		size = 1
+	}
+	a := &AsyncWriter{
+		w:      w,
+		buf:    bufio.NewWriter(w),
+		queue:  make([][]byte, size),
+		policy: policy,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	a.notFull = sync.NewCond(&a.mu)
+	go a.run(flushInterval)
+	return a
+}
+
+// AsyncWriter is an io.WriteCloser writing asynchronously on another io.Writer, by using a bounded queue.
+// Each call to Write is considered as a record, as done by the slog.Handler.
+type AsyncWriter struct {
+	w       io.Writer
+	buf     *bufio.Writer
+	queue   [][]byte
+	notFull *sync.Cond
+	wake    chan struct{}
+	done    chan struct{}
+	head    int
+	len     int
+	mu      sync.Mutex
+	once    sync.Once
+	closed  bool
+	policy  DropPolicy
+	// buffered is the number of records in buf, only used by the run goroutine.
+	buffered int64
+
+	droppedBytes   atomic.Int64
+	droppedRecords atomic.Int64
+}
+
+// Close stops accepting new records, waits for the pending ones to be written and flushed,
+// then closes the underlying writer if it implements the io.Closer interface.
+func (a *AsyncWriter) Close() (err error) {
+	a.once.Do(func() {
+		a.mu.Lock()
+		a.closed = true
+		a.notFull.Broadcast()
+		a.mu.Unlock()
+		a.notify()
+		<-a.done
+		if c, ok := a.w.(io.Closer); ok {
+			err = c.Close()
+		}
+	})
+	return err
+}
+
+// DroppedBytes returns the number of bytes dropped, due to the drop policy or to a write error.
+func (a *AsyncWriter) DroppedBytes() int64 {
+	return a.droppedBytes.Load()
+}
+
+// DroppedRecords returns the number of records dropped, due to the drop policy or to a write error.
+func (a *AsyncWriter) DroppedRecords() int64 {
+	return a.droppedRecords.Load()
+}
+
+// Write implements the io.Writer interface.
+// It enqueues a copy of p and only fails if the writer is closed.
+func (a *AsyncWriter) Write(p []byte) (n int, err error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.policy == Block && a.len == len(a.queue) && !a.closed {
+		a.notFull.Wait()
+	}
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+	if a.len == len(a.queue) {
+		if a.policy == DropNewest {
+			a.drop(b)
+			return len(p), nil
+		}
+		a.drop(a.queue[a.head])
+		a.queue[a.head] = nil
+		a.head = (a.head + 1) % len(a.queue)
+		a.len--
+	}
+	a.queue[(a.head+a.len)%len(a.queue)] = b
+	a.len++
+	a.notify()
+	return len(p), nil
+}
+
+func (a *AsyncWriter) drop(b []byte) {
+	a.droppedBytes.Add(int64(len(b)))
+	a.droppedRecords.Add(1)
+}
+
+func (a *AsyncWriter) notify() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (a *AsyncWriter) run(flushInterval time.Duration) {
+	defer close(a.done)
+	var tick <-chan time.Time
+	if flushInterval > 0 {
+		t := time.NewTicker(flushInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-tick:
+			a.flush()
+			continue
+		case <-a.wake:
+		}
+		closed := a.drain()
+		if tick == nil || closed {
+			a.flush()
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// drain writes all the records of the queue in the buffer and reports whether the writer is closed.
+func (a *AsyncWriter) drain() bool {
+	a.mu.Lock()
+	batch := make([][]byte, 0, a.len)
+	for ; a.len > 0; a.len-- {
+		batch = append(batch, a.queue[a.head])
+		a.queue[a.head] = nil
+		a.head = (a.head + 1) % len(a.queue)
+	}
+	closed := a.closed
+	a.notFull.Broadcast()
+	a.mu.Unlock()
+
+	for _, b := range batch {
+		n, err := a.buf.Write(b)
+		a.buffered++
+		if err != nil {
+			a.droppedBytes.Add(int64(len(b) - n))
+			a.discard()
+		}
+	}
+	return closed
+}
+
+func (a *AsyncWriter) flush() {
+	if err := a.buf.Flush(); err != nil {
+		a.discard()
+		return
+	}
+	a.buffered = 0
+}
+
+// discard drops the records of the buffer after a write error.
+// The buffer keeps the failing data: the writer is reset to allow the next writes.
+func (a *AsyncWriter) discard() {
+	a.droppedBytes.Add(int64(a.buf.Buffered()))
+	a.droppedRecords.Add(a.buffered)
+	a.buffered = 0
+	a.buf.Reset(a.w)
+}
@@ -0,0 +1,140 @@
+package logm_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/rvflash/logm"
+)
+
+// blockingWriter blocks on the first write until released.
+type blockingWriter struct {
+	buf      bytes.Buffer
+	entered  chan struct{}
+	released chan struct{}
+	once     sync.Once
+	closed   bool
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{entered: make(chan struct{}), released: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.entered)
+		<-w.released
+	})
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// errWriter always fails.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("oops")
+}
+
+func TestNewAsyncWriter(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		w   = logm.NewAsyncWriter(buf, 10, logm.Block, time.Hour)
+		log = logm.DefaultLogger(name, w)
+	)
+	for i := 0; i < 100; i++ {
+		log.Info(info)
+	}
+	are.NoErr(w.Close())                                   // unexpected close error
+	are.Equal(100, bytes.Count(buf.Bytes(), []byte(info))) // mismatch records
+	are.Equal(int64(0), w.DroppedRecords())                // unexpected dropped records
+	are.NoErr(w.Close())                                   // unexpected second close error
+	_, err := w.Write([]byte(info))
+	are.True(errors.Is(err, os.ErrClosed)) // mismatch write error
+}
+
+func TestAsyncWriter_Write(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		policy logm.DropPolicy
+		out    string
+		drop   int64
+	}{
+		"Block":       {policy: logm.Block, out: "abc"},
+		"Drop oldest": {policy: logm.DropOldest, out: "ac", drop: 1},
+		"Drop newest": {policy: logm.DropNewest, out: "ab", drop: 1},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				bw = newBlockingWriter()
+				w  = logm.NewAsyncWriter(bw, 1, tt.policy, 0)
+			)
+			_, err := w.Write([]byte("a"))
+			are.NoErr(err) // unexpected write error
+			<-bw.entered
+			_, err = w.Write([]byte("b"))
+			are.NoErr(err) // unexpected write error
+			if tt.policy == logm.Block {
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					close(bw.released)
+				}()
+			}
+			_, err = w.Write([]byte("c"))
+			are.NoErr(err) // unexpected write error
+			if tt.policy != logm.Block {
+				close(bw.released)
+			}
+			are.NoErr(w.Close())                   // unexpected close error
+			are.True(bw.closed)                    // expected closed writer
+			are.Equal(tt.out, bw.buf.String())     // mismatch output
+			are.Equal(tt.drop, w.DroppedRecords()) // mismatch dropped records
+			are.Equal(tt.drop, w.DroppedBytes())   // mismatch dropped bytes
+		})
+	}
+}
+
+func TestAsyncWriter_writeError(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		interval time.Duration
+		in       []string
+		bytes    int64
+	}{
+		"Flush":  {in: []string{"a", "b"}, bytes: 2},
+		"Buffer": {interval: time.Hour, in: []string{"a", strings.Repeat("b", 5000)}, bytes: 5001},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			w := logm.NewAsyncWriter(errWriter{}, 10, logm.Block, tt.interval)
+			for _, s := range tt.in {
+				_, err := w.Write([]byte(s))
+				are.NoErr(err) // unexpected write error
+			}
+			are.NoErr(w.Close())                             // unexpected close error
+			are.Equal(int64(len(tt.in)), w.DroppedRecords()) // mismatch dropped records
+			are.Equal(tt.bytes, w.DroppedBytes())            // mismatch dropped bytes
+		})
+	}
+}
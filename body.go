@@ -0,0 +1,128 @@
+package logm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slog"
+)
+
+func newBodyCapture(limit int) *bodyCapture {
+	return &bodyCapture{hash: sha256.New(), limit: limit}
+}
+
+// bodyCapture captures a body in a buffer capped to limit bytes.
+// The size and the hash are computed on the full body.
+type bodyCapture struct {
+	buf   bytes.Buffer
+	hash  hash.Hash
+	size  int64
+	limit int
+}
+
+// Write implements the io.Writer interface. It never fails.
+func (c *bodyCapture) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	_, _ = c.hash.Write(p)
+	if n := c.limit - c.buf.Len(); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		_, _ = c.buf.Write(p[:n])
+	}
+	return len(p), nil
+}
+
+// logAttrs returns the body inline if its content type is textual, its size and hash otherwise.
+// The inline body is masked by the Redactor, if any, and cut on a rune boundary if truncated.
+// If its sensitive values can not be masked, the body is logged by size and hash.
+func (c *bodyCapture) logAttrs(contentType string, r *Redactor) []slog.Attr {
+	if c.size == 0 {
+		return nil
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(c.buf.Bytes())
+	}
+	var (
+		truncated = c.size > int64(c.buf.Len())
+		body      = c.buf.Bytes()
+		ok        = isTextual(contentType)
+	)
+	if ok {
+		if truncated {
+			body = cutRune(body)
+		}
+		body, ok = r.Body(contentType, body)
+	}
+	if !ok {
+		return []slog.Attr{
+			slog.Int64(HTTPBodySizeKey, c.size),
+			slog.String(HTTPBodyHashKey, "sha256:"+hex.EncodeToString(c.hash.Sum(nil))),
+		}
+	}
+	attrs := []slog.Attr{
+		slog.String(HTTPBodyKey, string(body)),
+		slog.Int64(HTTPBodySizeKey, c.size),
+	}
+	if truncated {
+		attrs = append(attrs, slog.Bool(HTTPBodyTruncatedKey, true))
+	}
+	return attrs
+}
+
+// cutRune returns b without its last rune if it is incomplete.
+func cutRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+func isTextual(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(t, "text/"),
+		strings.HasSuffix(t, "+json"),
+		strings.HasSuffix(t, "+xml"):
+		return true
+	}
+	switch t {
+	case "application/json",
+		"application/xml",
+		"application/javascript",
+		"application/x-www-form-urlencoded",
+		"application/x-ndjson":
+		return true
+	}
+	return false
+}
+
+// teeReadCloser copies into w what is read from the io.ReadCloser.
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+// Read implements the io.Reader interface.
+func (r *teeReadCloser) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = r.w.Write(p[:n])
+	}
+	return
+}
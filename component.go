@@ -0,0 +1,126 @@
+package logm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// NewComponentLevels returns a new table of minimum log levels by component.
+func NewComponentLevels(levels map[string]slog.Level) *ComponentLevels {
+	c := &ComponentLevels{m: make(map[string]slog.Level, len(levels))}
+	for k, v := range levels {
+		c.m[k] = v
+	}
+	return c
+}
+
+// ParseComponentLevels parses a list of comma-separated component levels, like `payments=DEBUG,cache=WARN`.
+// It's designed to configure the component levels at startup, by using an environment variable for example.
+func ParseComponentLevels(s string) (*ComponentLevels, error) {
+	c := NewComponentLevels(nil)
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(rule, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("component level %q: missing component", rule)
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(v))); err != nil {
+			return nil, fmt.Errorf("component level %q: %w", rule, err)
+		}
+		c.m[k] = level
+	}
+	return c, nil
+}
+
+// ComponentLevels is a table of minimum log levels by component, safe for concurrent use.
+// It can be adjusted at runtime.
+type ComponentLevels struct {
+	m  map[string]slog.Level
+	mu sync.RWMutex
+}
+
+// Delete removes the minimum log level of this component.
+func (c *ComponentLevels) Delete(component string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.m, component)
+}
+
+// Level returns the minimum log level of this component, if defined.
+func (c *ComponentLevels) Level(component string) (slog.Level, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	l, ok := c.m[component]
+	return l, ok
+}
+
+// Set defines the minimum log level of this component.
+func (c *ComponentLevels) Set(component string, level slog.Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = make(map[string]slog.Level)
+	}
+	c.m[component] = level
+}
+
+// NewComponentHandler returns a slog.Handler wrapping h, where the minimum log level of a record is resolved
+// with the component of the logger, defined with `logger.With(logm.ComponentKey, "payments")`.
+// If the component has no level in these levels, the minimum log level of h is used.
+func NewComponentHandler(h slog.Handler, levels *ComponentLevels) slog.Handler {
+	return &componentHandler{h: h, levels: levels}
+}
+
+type componentHandler struct {
+	h         slog.Handler
+	levels    *ComponentLevels
+	component string
+	grouped   bool
+}
+
+// Enabled implements the slog.Handler interface.
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.component != "" {
+		if l, ok := h.levels.Level(h.component); ok {
+			return level >= l
+		}
+	}
+	return h.h.Enabled(ctx, level)
+}
+
+// Handle implements the slog.Handler interface.
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.h.Handle(ctx, r)
+}
+
+// WithAttrs implements the slog.Handler interface.
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.h = h.h.WithAttrs(attrs)
+	if !h.grouped {
+		for _, a := range attrs {
+			if a.Key == ComponentKey {
+				c.component = a.Value.String()
+			}
+		}
+	}
+	return &c
+}
+
+// WithGroup implements the slog.Handler interface.
+// The component must be defined outside any group.
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.h = h.h.WithGroup(name)
+	c.grouped = true
+	return &c
+}
@@ -0,0 +1,101 @@
+package logm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/rvflash/logm"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestParseComponentLevels(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		in  string
+		out map[string]slog.Level
+		ok  bool
+	}{
+		"Default": {ok: true},
+		"OK": {
+			in: " payments=debug, cache = WARN ,",
+			out: map[string]slog.Level{
+				"payments": slog.LevelDebug,
+				"cache":    slog.LevelWarn,
+			},
+			ok: true,
+		},
+		"Missing level":     {in: "payments"},
+		"Missing component": {in: "=DEBUG"},
+		"Invalid level":     {in: "payments=oops"},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			out, err := logm.ParseComponentLevels(tt.in)
+			are.Equal(tt.ok, err == nil) // mismatch error
+			for k, v := range tt.out {
+				l, ok := out.Level(k)
+				are.True(ok)    // missing component
+				are.Equal(v, l) // mismatch level
+			}
+		})
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		c   = logm.NewComponentLevels(map[string]slog.Level{"cache": slog.LevelWarn})
+	)
+	l, ok := c.Level("cache")
+	are.True(ok)                 // expected level
+	are.Equal(slog.LevelWarn, l) // mismatch level
+	c.Set("cache", slog.LevelError)
+	l, _ = c.Level("cache")
+	are.Equal(slog.LevelError, l) // mismatch updated level
+	c.Delete("cache")
+	_, ok = c.Level("cache")
+	are.True(!ok) // unexpected level
+}
+
+func TestNewComponentHandler(t *testing.T) {
+	t.Parallel()
+	var (
+		are    = is.New(t)
+		buf    = new(bytes.Buffer)
+		levels = logm.NewComponentLevels(map[string]slog.Level{
+			"payments": slog.LevelDebug,
+			"cache":    slog.LevelWarn,
+		})
+		log      = logm.New(name, logm.WithWriter(buf), logm.WithLevel(slog.LevelInfo), logm.WithComponentLevels(levels))
+		payments = log.With(logm.ComponentKey, "payments")
+		cache    = log.With(logm.ComponentKey, "cache")
+		grouped  = log.WithGroup("g").With(logm.ComponentKey, "payments")
+	)
+	log.Debug("none.debug")
+	log.Info("none.info")
+	payments.Debug("payments.debug")
+	cache.Info("cache.info")
+	cache.Warn("cache.warn")
+	grouped.Debug("grouped.debug")
+
+	levels.Set("cache", slog.LevelInfo)
+	cache.Info("cache.runtime")
+
+	out := buf.String()
+	are.True(!strings.Contains(out, "none.debug"))     // unexpected debug message
+	are.True(strings.Contains(out, "none.info"))       // missing info message
+	are.True(strings.Contains(out, "payments.debug"))  // missing payments debug message
+	are.True(!strings.Contains(out, "cache.info"))     // unexpected cache info message
+	are.True(strings.Contains(out, "cache.warn"))      // missing cache warn message
+	are.True(!strings.Contains(out, "grouped.debug"))  // unexpected grouped debug message
+	are.True(strings.Contains(out, "cache.runtime"))   // missing cache runtime message
+	are.True(strings.Contains(out, "component=cache")) // missing component
+}
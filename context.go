@@ -0,0 +1,128 @@
+package logm
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// ContextWithAttrs returns a copy of the context carrying these attributes, like a user ID or a tenant,
+// in addition to the ones already carried by ctx.
+// They are added to every record logged with this context by a logger built by New.
+func ContextWithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	prev := AttrsFromContext(ctx)
+	return context.WithValue(ctx, ctxAttrs, append(prev[:len(prev):len(prev)], attrs...))
+}
+
+// AttrsFromContext returns the attributes carried by the context, see ContextWithAttrs.
+func AttrsFromContext(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(ctxAttrs).([]slog.Attr)
+	return attrs
+}
+
+// NewContextHandler returns a slog.Handler wrapping h, adding to each record the attributes carried by its context:
+// the trace identifiers, see Trace.NewContext, and the attributes registered with ContextWithAttrs.
+// The span timing, attributes, events and status are only logged in its final record, see Trace.TimeElapsed.
+// The trace is not added if the record or the logger already have one, under the TraceKey or PanicKey name.
+// The context attributes stay at the top level, whatever the groups of the logger: these groups are kept
+// by the handler and only applied to the attributes of the record and the logger.
+func NewContextHandler(h slog.Handler) slog.Handler {
+	return &contextHandler{h: h}
+}
+
+type contextHandler struct {
+	h      slog.Handler
+	groups []attrGroup
+	traced bool
+}
+
+// attrGroup is a group opened by WithGroup, with the attributes added to it by WithAttrs.
+type attrGroup struct {
+	name  string
+	attrs []slog.Attr
+}
+
+// Enabled implements the slog.Handler interface.
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+// Handle implements the slog.Handler interface.
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	var (
+		attrs []slog.Attr
+		t     *Trace
+		ok    bool
+	)
+	if ctx != nil {
+		attrs = AttrsFromContext(ctx)
+		t, ok = TraceFromContext(ctx)
+	}
+	ok = ok && !h.traced
+	if ok {
+		r.Attrs(func(a slog.Attr) {
+			ok = ok && !isTraceAttr(a)
+		})
+	}
+	if len(attrs) == 0 && !ok && len(h.groups) == 0 {
+		return h.h.Handle(ctx, r)
+	}
+	r = h.group(r)
+	if ok {
+		r.AddAttrs(t.idAttr())
+	}
+	r.AddAttrs(attrs...)
+	return h.h.Handle(ctx, r)
+}
+
+// group returns a copy of the record where its attributes are nested in the groups of the handler.
+func (h *contextHandler) group(r slog.Record) slog.Record {
+	if len(h.groups) == 0 {
+		return r.Clone()
+	}
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) {
+		attrs = append(attrs, a)
+	})
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		g := h.groups[i]
+		attrs = []slog.Attr{slog.Group(g.name, append(g.attrs[:len(g.attrs):len(g.attrs)], attrs...)...)}
+	}
+	res := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	res.AddAttrs(attrs...)
+	return res
+}
+
+// WithAttrs implements the slog.Handler interface.
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	n := len(h.groups)
+	if n == 0 {
+		c.h = h.h.WithAttrs(attrs)
+		for _, a := range attrs {
+			c.traced = c.traced || isTraceAttr(a)
+		}
+		return &c
+	}
+	last := h.groups[n-1]
+	last.attrs = append(last.attrs[:len(last.attrs):len(last.attrs)], attrs...)
+	c.groups = append(h.groups[:n-1:n-1], last)
+	return &c
+}
+
+// WithGroup implements the slog.Handler interface.
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.groups = append(h.groups[:len(h.groups):len(h.groups)], attrGroup{name: name})
+	return &c
+}
+
+func isTraceAttr(a slog.Attr) bool {
+	return a.Key == TraceKey || a.Key == PanicKey
+}
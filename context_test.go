@@ -0,0 +1,142 @@
+package logm_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/rvflash/logm"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestContextWithAttrs(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		ctx = logm.ContextWithAttrs(context.Background(), slog.String("user", "u1"))
+		sub = logm.ContextWithAttrs(ctx, slog.String("tenant", "t1"))
+	)
+	are.Equal(ctx, logm.ContextWithAttrs(ctx))                     // unexpected new context
+	are.Equal(1, len(logm.AttrsFromContext(ctx)))                  // mismatch parent attributes
+	are.Equal(2, len(logm.AttrsFromContext(sub)))                  // mismatch child attributes
+	are.Equal(0, len(logm.AttrsFromContext(context.Background()))) // unexpected attributes
+}
+
+func TestNewContextHandler(t *testing.T) {
+	t.Parallel()
+	tr := logm.NewTraceSpan(traceID)
+	for desc, tc := range map[string]struct {
+		ctx  context.Context
+		log  func(ctx context.Context, l *slog.Logger)
+		in   []string
+		out  []string
+		once string
+	}{
+		"Default": {
+			ctx: context.Background(),
+			log: func(ctx context.Context, l *slog.Logger) { l.InfoCtx(ctx, info) },
+			out: []string{"trace.", "user="},
+		},
+		"Trace": {
+			ctx:  tr.NewContext(context.Background()),
+			log:  func(ctx context.Context, l *slog.Logger) { l.InfoCtx(ctx, info) },
+			in:   []string{"trace.id=" + traceID},
+			once: "trace.id=",
+		},
+		"Span identifiers only": {
+			ctx: (&logm.Trace{
+				ID:        traceID,
+				StartTime: time.Now(),
+				Attrs:     []slog.Attr{slog.Bool("cache.hit", true)},
+				Events:    []logm.TraceEvent{{Name: "e1"}},
+				Status:    logm.TraceStatusOK,
+			}).NewContext(context.Background()),
+			log:  func(ctx context.Context, l *slog.Logger) { l.InfoCtx(ctx, info) },
+			in:   []string{"trace.id=" + traceID},
+			out:  []string{"trace.attrs.", "trace.events.", "trace.status=", "trace.time_elapsed_ms="},
+			once: "trace.id=",
+		},
+		"Attributes": {
+			ctx: logm.ContextWithAttrs(tr.NewContext(context.Background()), slog.String("user", "u1")),
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.LogAttrs(ctx, slog.LevelInfo, info, slog.Int("n", 1))
+			},
+			in:   []string{"n=1 trace.id=" + traceID, "user=u1"},
+			once: "trace.id=",
+		},
+		"Group": {
+			ctx: logm.ContextWithAttrs(tr.NewContext(context.Background()), slog.String("user", "u1")),
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.WithGroup("g").InfoCtx(ctx, info, "n", 1)
+			},
+			in:   []string{"g.n=1", "trace.id=" + traceID, "user=u1"},
+			out:  []string{"g.trace.", "g.user="},
+			once: "trace.id=",
+		},
+		"Nested groups": {
+			ctx: tr.NewContext(context.Background()),
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.With("a", 0).WithGroup("g").With("b", 1).WithGroup("h").With("c", 2).InfoCtx(ctx, info, "d", 3)
+			},
+			in:   []string{"a=0 g.b=1 g.h.c=2 g.h.d=3 trace.id=" + traceID},
+			once: "trace.id=",
+		},
+		"Empty group": {
+			ctx: tr.NewContext(context.Background()),
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.WithGroup("g").InfoCtx(ctx, info)
+			},
+			in:  []string{"trace.id=" + traceID},
+			out: []string{"g."},
+		},
+		"Group without context": {
+			ctx: context.Background(),
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.WithGroup("g").With("a", 0).InfoCtx(ctx, info, "b", 1)
+			},
+			in:  []string{"g.a=0 g.b=1"},
+			out: []string{"trace."},
+		},
+		"Record trace": {
+			ctx: tr.NewContext(context.Background()),
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.LogAttrs(ctx, slog.LevelInfo, info, tr.LogAttr())
+			},
+			in:   []string{"trace.id=" + traceID},
+			once: "trace.id=",
+		},
+		"Logger trace": {
+			ctx: tr.NewContext(context.Background()),
+			log: func(ctx context.Context, l *slog.Logger) {
+				l.With(tr.LogAttr()).InfoCtx(ctx, info)
+			},
+			in:   []string{"trace.id=" + traceID},
+			once: "trace.id=",
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				are = is.New(t)
+				buf = new(bytes.Buffer)
+			)
+			tt.log(tt.ctx, logm.DefaultLogger(name, buf))
+			out := buf.String()
+			for _, s := range tt.in {
+				are.True(strings.Contains(out, s)) // missing attribute
+			}
+			for _, s := range tt.out {
+				are.True(!strings.Contains(out, s)) // unexpected attribute
+			}
+			if tt.once != "" {
+				are.Equal(1, strings.Count(out, tt.once)) // duplicated attribute
+			}
+		})
+	}
+}
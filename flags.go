@@ -6,8 +6,12 @@ const (
 	AppNameKey = "app"
 	// AppVersionKey is the version of the application in structured log.
 	AppVersionKey = "version"
+	// ComponentKey is the name of the component of the application in structured log.
+	ComponentKey = "component"
 	// HTTPRequestKey is the HTTP request name in structured log.
 	HTTPRequestKey = "req"
+	// HTTPURLKey is the HTTP request URL in structured log.
+	HTTPURLKey = "url"
 	// HTTPPathKey is the HTTP request path in structured log.
 	HTTPPathKey = "path"
 	// HTTPMethodKey is the HTTP request method in structured log.
@@ -16,20 +20,69 @@ const (
 	HTTPRemoteAddrKey = "remote_addr"
 	// HTTPQueryKey is the HTTP request query in structured log.
 	HTTPQueryKey = "query"
+	// HTTPBodyKey is the HTTP body in structured log.
+	HTTPBodyKey = "body"
+	// HTTPBodyHashKey is the hash of an HTTP binary body in structured log.
+	HTTPBodyHashKey = "body_hash"
+	// HTTPBodySizeKey is the HTTP body size in structured log.
+	HTTPBodySizeKey = "body_size"
+	// HTTPBodyTruncatedKey reports whether the HTTP body has been truncated in structured log.
+	HTTPBodyTruncatedKey = "body_truncated"
+	// HTTPHeaderKey is the HTTP headers name in structured log.
+	HTTPHeaderKey = "header"
+	// HTTPHeaderWrittenKey reports whether the HTTP response header has been written in structured log.
+	HTTPHeaderWrittenKey = "header_written"
+	// HTTPHijackedKey reports whether the HTTP connection has been hijacked in structured log.
+	HTTPHijackedKey = "hijacked"
+	// HTTPPartialKey reports whether the client got a partial HTTP response in structured log.
+	HTTPPartialKey = "partial"
 	// HTTPResponseKey is the HTTP response name in structured log.
 	HTTPResponseKey = "resp"
 	// HTTPStatusKey is the HTTP response status in structured log.
 	HTTPStatusKey = "status"
 	// HTTPSizeKey is the HTTP response size in structured log.
 	HTTPSizeKey = "size"
+	// HTTPTimeToFirstByteKey is the time to first byte of the HTTP response in structured log.
+	HTTPTimeToFirstByteKey = "ttfb"
 	// PanicKey is a panic in structured log.
 	PanicKey = "panic"
+	// SamplingDroppedKey is the number of records dropped by sampling in structured log.
+	SamplingDroppedKey = "dropped"
+	// SamplingLevelKey is the level of the records dropped by sampling in structured log.
+	SamplingLevelKey = "sampled_level"
+	// SamplingMessageKey is the message of the records dropped by sampling in structured log.
+	SamplingMessageKey = "sampled_msg"
+	// StackKey is the name of a stack trace in structured log.
+	StackKey = "stack"
+	// StackFunctionKey is the function name of a stack frame in structured log.
+	StackFunctionKey = "func"
+	// StackFileKey is the file name of a stack frame in structured log.
+	StackFileKey = "file"
+	// StackLineKey is the line number of a stack frame in structured log.
+	StackLineKey = "line"
+	// TraceDurationKey is the prefix of the name of the trace duration in structured log,
+	// suffixed by its unit, like `duration_ms`. See DurationUnit.
+	TraceDurationKey = "duration"
+	// TraceAttrsKey is the name of the trace span attributes in structured log.
+	TraceAttrsKey = "attrs"
+	// TraceErrorKey is the name of the trace span error in structured log.
+	TraceErrorKey = "error"
+	// TraceEventsKey is the name of the trace span events in structured log.
+	TraceEventsKey = "events"
+	// TraceEventNameKey is the name of a trace span event name in structured log.
+	TraceEventNameKey = "name"
+	// TraceEventTimeKey is the name of a trace span event time in structured log.
+	TraceEventTimeKey = "time"
 	// TraceKey is the name of the trace in structured log.
 	TraceKey = "trace"
 	// TraceIDKey is the name of the trace ID in structured log.
 	TraceIDKey = "id"
 	// TraceSpanIDKey is the name of the trace span ID in structured log.
 	TraceSpanIDKey = "span_id"
+	// TraceParentSpanIDKey is the name of the trace parent span ID in structured log.
+	TraceParentSpanIDKey = "parent_span_id"
+	// TraceStatusKey is the name of the trace span status in structured log.
+	TraceStatusKey = "status"
 	// TraceTimeElapsedKey is the name of the trace time in structured log.
 	TraceTimeElapsedKey = "time_elapsed_ms"
 )
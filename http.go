@@ -1,122 +1,573 @@
 package logm
 
 import (
+	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
-	"runtime/debug"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/exp/slog"
 )
 
 // Middleware provides some standard HTTP handlers to deal with logs.
+// The sensitive query parameters, headers and bodies are masked by the Redactor, by default the one
+// applying the DefaultRedactRules. An empty Redactor, built by NewRedactor without rule, masks nothing.
+// RequestHeaders and ResponseHeaders are the allowlists of headers to log, like `User-Agent` or `Content-Type`.
+// Whatever the Redactor, the Authorization, Proxy-Authorization, Cookie and Set-Cookie headers are masked.
+// BodyLimit enables the capture of the request and response bodies, up to this number of bytes.
+// They're logged in a DEBUG record, see LogHandler.
+// PanicRenderer writes the error response of a recovered panic, by default ErrorMessage as plain text.
+// See ProblemRenderer for an RFC 7807 problem document.
+// IDGenerator generates the identifiers of the new traces, by default the one defined by SetIDGenerator.
+// StackDepth is the maximum number of frames of the stack trace logged on panic, by default DefaultStackDepth.
+// LevelFunc defines the log level of a request based on its response status code, by default DefaultStatusLevel.
+// SkipPaths lists the request paths to never log, like `/healthz` or `/metrics`. See path.Match for the syntax.
 type Middleware struct {
-	Logger       *slog.Logger
-	ErrorMessage string
+	Logger          *slog.Logger
+	Redactor        *Redactor
+	LevelFunc       func(code int) slog.Level
+	PanicRenderer   PanicRenderer
+	IDGenerator     IDGenerator
+	ErrorMessage    string
+	RequestHeaders  []string
+	ResponseHeaders []string
+	SkipPaths       []string
+	BodyLimit       int
+	StackDepth      int
 }
 
-// LogHandler is an HTTP middleware designed to log every request and response.
+// PanicRenderer writes the response of the request r which panicked with err, as recovered in the trace t.
+type PanicRenderer func(w http.ResponseWriter, r *http.Request, err error, t *Trace)
+
+// ProblemContentType is the content type of an RFC 7807 problem document.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem document, with the trace ID as extension member.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+}
+
+// ProblemRenderer returns a PanicRenderer writing an RFC 7807 problem document as internal server error.
+// The detail is optional, the error is never exposed to the client.
+// The trace ID is provided to allow the client to quote it to the support.
+func ProblemRenderer(detail string) PanicRenderer {
+	return func(w http.ResponseWriter, r *http.Request, _ error, t *Trace) {
+		p := Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(http.StatusInternalServerError),
+			Status:   http.StatusInternalServerError,
+			Detail:   detail,
+			Instance: r.URL.Path,
+		}
+		if t != nil {
+			p.TraceID = t.ID
+		}
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(p.Status)
+		_ = json.NewEncoder(w).Encode(p)
+	}
+}
+
+// DefaultStatusLevel returns the log level of a request based on its response status code:
+// ERROR for 5xx, WARN for 4xx, otherwise INFO.
+func DefaultStatusLevel(code int) slog.Level {
+	switch {
+	case code >= http.StatusInternalServerError:
+		return slog.LevelError
+	case code >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// LogHandler is an HTTP middleware designed to log every request and response, except the skipped paths.
+// The log level depends on the response status code, see LevelFunc.
+// If a BodyLimit is defined and the DEBUG level enabled, the request and response bodies are also
+// logged in a DEBUG record: inline for textual content types, by size and hash otherwise.
+// The inline form-urlencoded and JSON bodies are masked by the Redactor, see Redactor.Body.
+// The bodies are copied while streamed, without delaying the handler.
+// Its trace is shared with the next handlers in the request context, see TraceFromContext.
 func (m Middleware) LogHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t := NewTraceFromHTTPRequest(r)
+		if m.skip(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t := m.newTrace(r)
 		t.Start()
-		wh := newHTTPResponseWriter(w)
-		next.ServeHTTP(wh, r)
+		var (
+			ctx      = r.Context()
+			wh       = newHTTPResponseWriter(w)
+			req, res *bodyCapture
+		)
+		// Shares the trace with the next handlers, like RecoverHandler, to log the same trace ID.
+		r = r.WithContext(t.NewContext(ctx))
+		if m.BodyLimit > 0 && m.Logger.Enabled(ctx, slog.LevelDebug) {
+			req, res = newBodyCapture(m.BodyLimit), newBodyCapture(m.BodyLimit)
+			if r.Body != nil && r.Body != http.NoBody {
+				// Only on the copy made by WithContext: the request received must not be modified.
+				r.Body = &teeReadCloser{ReadCloser: r.Body, w: req}
+			}
+			wh.body = res
+		}
+		next.ServeHTTP(wh.wrap(), r)
 		t.End()
-		m.Logger.Info(
-			fmt.Sprintf("%d %s %s", wh.statusCode, r.Method, r.URL.Path),
-			logHTTPRequest(r),
-			logHTTPResponse(wh),
+		var (
+			code = wh.statusCode
+			msg  = fmt.Sprintf("%d %s %s", code, r.Method, r.URL.Path)
+		)
+		if wh.hijacked {
+			// The response status is unknown, the connection is taken over by the handler.
+			code = http.StatusSwitchingProtocols
+			msg = fmt.Sprintf("hijacked %s %s", r.Method, r.URL.Path)
+		}
+		m.Logger.LogAttrs(ctx, m.level(code), msg,
+			m.logHTTPRequest(r),
+			m.logHTTPResponse(wh),
 			t.LogAttr(),
 		)
+		if req != nil {
+			m.Logger.LogAttrs(ctx, slog.LevelDebug, msg,
+				slog.Group(HTTPRequestKey, req.logAttrs(r.Header.Get("Content-Type"), m.redactor())...),
+				slog.Group(HTTPResponseKey, res.logAttrs(wh.Header().Get("Content-Type"), m.redactor())...),
+				t.LogAttr(),
+			)
+		}
 	})
 }
 
-func logHTTPRequest(r *http.Request) slog.Attr {
-	return slog.Group(HTTPRequestKey,
+// newTrace returns a new span of the trace of the request context, shared by TraceHandler or LogHandler,
+// otherwise of the trace of the request headers.
+func (m Middleware) newTrace(r *http.Request) *Trace {
+	if t, ok := TraceFromContext(r.Context()); ok {
+		return t.NewSpan()
+	}
+	return newTraceFromHTTPRequest(r, m.IDGenerator)
+}
+
+func (m Middleware) level(code int) slog.Level {
+	if m.LevelFunc == nil {
+		return DefaultStatusLevel(code)
+	}
+	return m.LevelFunc(code)
+}
+
+func (m Middleware) skip(p string) bool {
+	for _, pattern := range m.SkipPaths {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Middleware) logHTTPRequest(r *http.Request) slog.Attr {
+	attrs := []slog.Attr{
 		slog.String(HTTPPathKey, r.URL.Path),
 		slog.String(HTTPMethodKey, r.Method),
 		slog.String(HTTPRemoteAddrKey, r.RemoteAddr),
-		slog.String(HTTPQueryKey, r.URL.RawQuery),
-	)
+		slog.String(HTTPQueryKey, m.redactor().Query(r.URL.RawQuery)),
+	}
+	if len(m.RequestHeaders) > 0 {
+		// The server moves these headers in dedicated fields of the request.
+		h := r.Header.Clone()
+		if h == nil {
+			h = http.Header{}
+		}
+		if r.ContentLength > 0 {
+			h.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+		}
+		if r.Host != "" {
+			h.Set("Host", r.Host)
+		}
+		attrs = append(attrs, m.logHTTPHeader(h, m.RequestHeaders))
+	}
+	return slog.Group(HTTPRequestKey, attrs...)
 }
 
-func logHTTPResponse(w *httpResponseWriter) slog.Attr {
-	return slog.Group(HTTPResponseKey,
-		slog.Int(HTTPStatusKey, w.statusCode),
-		slog.Int(HTTPSizeKey, w.size),
-	)
+func (m Middleware) logHTTPResponse(w *httpResponseWriter) slog.Attr {
+	var attrs []slog.Attr
+	if w.hijacked {
+		attrs = []slog.Attr{
+			slog.Bool(HTTPHijackedKey, true),
+			slog.Int64(HTTPSizeKey, w.size),
+		}
+	} else {
+		attrs = []slog.Attr{
+			slog.Int(HTTPStatusKey, w.statusCode),
+			slog.Int64(HTTPSizeKey, w.size),
+			slog.Bool(HTTPHeaderWrittenKey, w.wroteHeader),
+		}
+		if w.wroteHeader {
+			attrs = append(attrs, slog.Duration(HTTPTimeToFirstByteKey, w.ttfb))
+		}
+	}
+	if len(m.ResponseHeaders) > 0 {
+		attrs = append(attrs, m.logHTTPHeader(w.Header(), m.ResponseHeaders))
+	}
+	return slog.Group(HTTPResponseKey, attrs...)
+}
+
+// defaultRedactor is the Redactor used by default by the Middleware and the Transport.
+var defaultRedactor = NewRedactor(DefaultRedactRules()...)
+
+func (m Middleware) redactor() *Redactor {
+	if m.Redactor == nil {
+		return defaultRedactor
+	}
+	return m.Redactor
+}
+
+// secretHTTPHeaders masks the HTTP headers always considered as sensitive.
+var secretHTTPHeaders = NewRedactor(
+	RedactRule{Pattern: "authorization"},
+	RedactRule{Pattern: "proxy-authorization"},
+	RedactRule{Pattern: "cookie"},
+	RedactRule{Pattern: "set-cookie"},
+)
+
+// logHTTPHeader returns the values of these header keys, by using their lowercase name as key.
+// Multiple values are joined with a comma.
+func (m Middleware) logHTTPHeader(h http.Header, keys []string) slog.Attr {
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		a := h.Values(k)
+		if len(a) == 0 {
+			continue
+		}
+		var (
+			key      = strings.ToLower(k)
+			val      = strings.Join(a, ", ")
+			mask, ok = m.redactor().match(key)
+		)
+		if !ok {
+			mask, ok = secretHTTPHeaders.match(key)
+		}
+		if ok {
+			if val, ok = mask(val); !ok {
+				continue
+			}
+		}
+		attrs = append(attrs, slog.String(key, val))
+	}
+	return slog.Group(HTTPHeaderKey, attrs...)
 }
 
 func newHTTPResponseWriter(w http.ResponseWriter) *httpResponseWriter {
-	return &httpResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	return &httpResponseWriter{ResponseWriter: w, statusCode: http.StatusOK, start: time.Now()}
 }
 
+// httpResponseWriter wraps an http.ResponseWriter to follow the response.
+// The status code is the first one committed, explicitly by WriteHeader or implicitly by Write, Flush
+// or ReadFrom. Until then, the status code is the default one: 200.
+// See wrap to expose the optional interfaces of the underlying writer.
+// Unwrap allows the http.ResponseController to access the underlying writer.
 type httpResponseWriter struct {
 	http.ResponseWriter
-	size       int
-	statusCode int
+	start       time.Time
+	body        *bodyCapture
+	size        int64
+	ttfb        time.Duration
+	statusCode  int
+	hijacked    bool
+	wroteHeader bool
+}
+
+// trackedResponseWriter is implemented by the writers returned by httpResponseWriter.wrap.
+type trackedResponseWriter interface {
+	tracked() *httpResponseWriter
+}
+
+func (w *httpResponseWriter) tracked() *httpResponseWriter {
+	return w
 }
 
-// Write wraps the response writer to follow the response size.
+type (
+	flusher    struct{ w *httpResponseWriter }
+	hijacker   struct{ w *httpResponseWriter }
+	pusher     struct{ w *httpResponseWriter }
+	readerFrom struct{ w *httpResponseWriter }
+)
+
+// Flush implements the http.Flusher interface.
+func (f flusher) Flush() {
+	f.w.commit(http.StatusOK)
+	_ = http.NewResponseController(f.w.ResponseWriter).Flush()
+}
+
+// Hijack implements the http.Hijacker interface.
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c, rw, err := h.w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		h.w.hijacked = true
+	}
+	return c, rw, err
+}
+
+// Push implements the http.Pusher interface.
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// ReadFrom implements the io.ReaderFrom interface, used by io.Copy.
+func (r readerFrom) ReadFrom(src io.Reader) (n int64, err error) {
+	if r.w.body != nil {
+		// Hides the ReadFrom method to io.Copy to avoid a recursive call.
+		return io.Copy(struct{ io.Writer }{r.w}, src)
+	}
+	r.w.commit(http.StatusOK)
+	n, err = r.w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	r.w.size += n
+	return
+}
+
+// wrap returns the writer to give to the handler: it only implements the optional interfaces
+// implemented by the underlying writer, among http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom.
+// This way, a handler probing them, like a server-sent events one with http.Flusher, is not misled.
+func (w *httpResponseWriter) wrap() http.ResponseWriter {
+	var (
+		f, h, p, r = flusher{w}, hijacker{w}, pusher{w}, readerFrom{w}
+		set        int
+	)
+	if _, ok := w.ResponseWriter.(http.Flusher); ok {
+		set |= 1
+	}
+	if _, ok := w.ResponseWriter.(http.Hijacker); ok {
+		set |= 2
+	}
+	if _, ok := w.ResponseWriter.(http.Pusher); ok {
+		set |= 4
+	}
+	if _, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		set |= 8
+	}
+	switch set {
+	case 1:
+		return struct {
+			*httpResponseWriter
+			flusher
+		}{w, f}
+	case 2:
+		return struct {
+			*httpResponseWriter
+			hijacker
+		}{w, h}
+	case 3:
+		return struct {
+			*httpResponseWriter
+			flusher
+			hijacker
+		}{w, f, h}
+	case 4:
+		return struct {
+			*httpResponseWriter
+			pusher
+		}{w, p}
+	case 5:
+		return struct {
+			*httpResponseWriter
+			flusher
+			pusher
+		}{w, f, p}
+	case 6:
+		return struct {
+			*httpResponseWriter
+			hijacker
+			pusher
+		}{w, h, p}
+	case 7:
+		return struct {
+			*httpResponseWriter
+			flusher
+			hijacker
+			pusher
+		}{w, f, h, p}
+	case 8:
+		return struct {
+			*httpResponseWriter
+			readerFrom
+		}{w, r}
+	case 9:
+		return struct {
+			*httpResponseWriter
+			flusher
+			readerFrom
+		}{w, f, r}
+	case 10:
+		return struct {
+			*httpResponseWriter
+			hijacker
+			readerFrom
+		}{w, h, r}
+	case 11:
+		return struct {
+			*httpResponseWriter
+			flusher
+			hijacker
+			readerFrom
+		}{w, f, h, r}
+	case 12:
+		return struct {
+			*httpResponseWriter
+			pusher
+			readerFrom
+		}{w, p, r}
+	case 13:
+		return struct {
+			*httpResponseWriter
+			flusher
+			pusher
+			readerFrom
+		}{w, f, p, r}
+	case 14:
+		return struct {
+			*httpResponseWriter
+			hijacker
+			pusher
+			readerFrom
+		}{w, h, p, r}
+	case 15:
+		return struct {
+			*httpResponseWriter
+			flusher
+			hijacker
+			pusher
+			readerFrom
+		}{w, f, h, p, r}
+	default:
+		return w
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter, as expected by the http.ResponseController.
+func (w *httpResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Write wraps the response writer to follow the response size and capture the body if required.
 func (w *httpResponseWriter) Write(data []byte) (n int, err error) {
+	w.commit(http.StatusOK)
 	n, err = w.ResponseWriter.Write(data)
-	w.size += n
+	w.size += int64(n)
+	if w.body != nil {
+		_, _ = w.body.Write(data[:n])
+	}
 	return
 }
 
-// WriteHeader captures the HTTP response header.
+// WriteHeader captures the HTTP response status code, only the first committed one is kept.
+// The informational status codes, except 101, can precede the final one and are ignored.
 func (w *httpResponseWriter) WriteHeader(code int) {
-	w.statusCode = code
+	if code < http.StatusContinue || code >= http.StatusOK || code == http.StatusSwitchingProtocols {
+		w.commit(code)
+	}
 	w.ResponseWriter.WriteHeader(code)
 }
 
-// RecoverHandler is an HTTP middleware designed to recover on panic, log the error and debug the stack trace.
+// commit captures the first status code sent and the time to first byte.
+func (w *httpResponseWriter) commit(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = code
+	w.ttfb = time.Since(w.start)
+}
+
+// RecoverHandler is an HTTP middleware designed to recover on panic, log the error and its stack trace.
 // If no error message is provided, we used the default internal error message.
+// A PanicRenderer can be provided to customize the error response, like ProblemRenderer.
+// The error response is only sent if the response has not already been committed, otherwise the client
+// got a partial response, as logged. The logged response is the one received by the client.
+// The http.ErrAbortHandler panic, used to abort a handler, is not recovered.
+// The trace of the request context, shared by TraceHandler or LogHandler, identifies the panic
+// and is given to the PanicRenderer, so both records and the error response share the same trace ID.
 func (m Middleware) RecoverHandler(next http.Handler) http.Handler {
-	msg := m.ErrorMessage
-	if msg == "" {
-		msg = http.StatusText(http.StatusInternalServerError)
+	render := m.PanicRenderer
+	if render == nil {
+		msg := m.ErrorMessage
+		if msg == "" {
+			msg = http.StatusText(http.StatusInternalServerError)
+		}
+		render = func(w http.ResponseWriter, _ *http.Request, _ error, _ *Trace) {
+			http.Error(w, msg, http.StatusInternalServerError)
+		}
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wh *httpResponseWriter
+		if tw, ok := w.(trackedResponseWriter); ok {
+			wh = tw.tracked()
+		} else {
+			wh = newHTTPResponseWriter(w)
+			w = wh.wrap()
+		}
 		defer func() {
 			pr := recover()
 			if pr != nil {
-				var err error
-				switch t := pr.(type) {
-				case string:
-					err = errors.New(t)
-				case error:
-					err = t
-				default:
-					err = fmt.Errorf("unsupported panic type: %#v", t)
+				err := PanicError(pr)
+				if errors.Is(err, http.ErrAbortHandler) {
+					panic(pr)
+				}
+				var (
+					stack   = NewPanicStack(m.StackDepth)
+					partial = wh.wroteHeader || wh.hijacked
+				)
+				t, ok := TraceFromContext(r.Context())
+				if !ok {
+					t = newTraceFromHTTPRequest(r, m.IDGenerator)
 				}
-				t := NewTraceFromHTTPRequest(r)
-				m.Logger.Error(err.Error(), PanicKey, t, logHTTPRequest(r))
-				m.Logger.Debug(string(debug.Stack()), PanicKey, t, logHTTPRequest(r))
-				http.Error(w, msg, http.StatusInternalServerError)
+				if !partial {
+					// Renders the error response first to log the status code received by the client.
+					render(wh, r, err, t)
+				}
+				resp := slog.Group(HTTPResponseKey,
+					slog.Int(HTTPStatusKey, wh.statusCode),
+					slog.Int64(HTTPSizeKey, wh.size),
+					slog.Bool(HTTPPartialKey, partial),
+				)
+				m.Logger.Error(err.Error(), slog.Group(PanicKey, t.idAttrs()...), m.logHTTPRequest(r), resp, StackKey, stack)
 			}
 		}()
 		next.ServeHTTP(w, r)
 	})
 }
 
+// TraceHandler is an HTTP middleware designed to share the trace context in the request context.
+// Without valid inbound trace identifier, a new one is generated by the IDGenerator.
+func (m Middleware) TraceHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := newTraceFromHTTPRequest(r, m.IDGenerator)
+		r = r.WithContext(t.NewContext(r.Context()))
+		t.SetHTTPHeader(r.Header)
+		next.ServeHTTP(w, r)
+	})
+}
+
 // LogHandler is an HTTP middleware designed to log every request and response.
 func LogHandler(l *slog.Logger, next http.Handler) http.Handler {
 	return Middleware{Logger: l}.LogHandler(next)
 }
 
-// RecoverHandler is an HTTP middleware designed to recover on panic, log the error and debug the stack trace.
+// RecoverHandler is an HTTP middleware designed to recover on panic, log the error and its stack trace.
 func RecoverHandler(msg string, l *slog.Logger, next http.Handler) http.Handler {
 	return Middleware{ErrorMessage: msg, Logger: l}.RecoverHandler(next)
 }
 
 // TraceHandler is an HTTP middleware designed to share the trace context in the request context.
 func TraceHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t := NewTraceFromHTTPRequest(r)
-		r = r.WithContext(t.NewContext(r.Context()))
-		r.Header.Set(TraceIDHTTPHeader, t.ID)
-		next.ServeHTTP(w, r)
-	})
+	return Middleware{}.TraceHandler(next)
 }
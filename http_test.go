@@ -2,6 +2,9 @@ package logm_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,6 +12,8 @@ import (
 
 	"github.com/matryer/is"
 	"github.com/rvflash/logm"
+
+	"golang.org/x/exp/slog"
 )
 
 const (
@@ -27,19 +32,411 @@ func TestLogHandler(t *testing.T) {
 		buf = new(bytes.Buffer)
 		log = logm.DefaultLogger(name, buf)
 		hdl = logm.LogHandler(log, next)
-		req = httptest.NewRequest(http.MethodGet, target, nil)
+		req = httptest.NewRequest(http.MethodGet, target+"?token="+secret, nil)
 		res = httptest.NewRecorder()
 	)
 	hdl.ServeHTTP(res, req)
 	out := buf.String()
-	are.True(strings.Contains(out, "200 GET /"))        // message expected
-	are.True(strings.Contains(out, "app=app"))          // application expected
-	are.True(strings.Contains(out, "resp.size=5"))      // response size expected
-	are.True(strings.Contains(out, "req.path=/"))       // request path expected
-	are.True(strings.Contains(out, "req.method=GET"))   // request method expected
-	are.True(strings.Contains(out, "req.remote_addr=")) // remote addr path expected
-	are.True(strings.Contains(out, "req.query="))       // request query expected
-	are.True(strings.Contains(out, "trace.id="))        // request trace id expected
+	are.True(strings.Contains(out, "200 GET /"))                   // message expected
+	are.True(strings.Contains(out, "app=app"))                     // application expected
+	are.True(strings.Contains(out, "resp.size=5"))                 // response size expected
+	are.True(strings.Contains(out, "req.path=/"))                  // request path expected
+	are.True(strings.Contains(out, "req.method=GET"))              // request method expected
+	are.True(strings.Contains(out, "req.remote_addr="))            // remote addr path expected
+	are.True(strings.Contains(out, `req.query="token=%2A%2A%2A"`)) // request query redacted by default expected
+	are.True(!strings.Contains(out, secret))                       // unexpected secret
+	are.True(strings.Contains(out, "trace.id="))                   // request trace id expected
+}
+
+func TestMiddleware_LogHandler(t *testing.T) {
+	t.Parallel()
+	var (
+		are  = is.New(t)
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(info))
+		})
+		buf = new(bytes.Buffer)
+		mdw = logm.Middleware{
+			Logger:   logm.DefaultLogger(name, buf),
+			Redactor: logm.NewRedactor(logm.DefaultRedactRules()...),
+		}
+		req = httptest.NewRequest(http.MethodGet, target+"?q=1&token="+secret, nil)
+		res = httptest.NewRecorder()
+	)
+	mdw.LogHandler(next).ServeHTTP(res, req)
+	out := buf.String()
+	are.True(strings.Contains(out, `req.query="q=1&token=%2A%2A%2A"`)) // redacted request query expected
+	are.True(!strings.Contains(out, secret))                           // unexpected secret
+}
+
+func TestMiddleware_LogHandler_headers(t *testing.T) {
+	t.Parallel()
+	var (
+		are  = is.New(t)
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Set-Cookie", "id="+secret)
+			_, _ = w.Write([]byte(info))
+		})
+		buf = new(bytes.Buffer)
+		mdw = logm.Middleware{
+			Logger:          logm.DefaultLogger(name, buf),
+			Redactor:        logm.NewRedactor(logm.RedactRule{Pattern: "x-api-key", Mask: logm.MaskDrop}),
+			RequestHeaders:  []string{"User-Agent", "authorization", "Cookie", "Content-Length", "X-Api-Key", "Referer"},
+			ResponseHeaders: []string{"Content-Type", "Set-Cookie"},
+		}
+		req = httptest.NewRequest(http.MethodPost, target, strings.NewReader(info))
+		res = httptest.NewRecorder()
+	)
+	req.Header.Set("User-Agent", "logm")
+	req.Header.Set("Authorization", "Bearer "+secret)
+	req.Header.Add("Cookie", "a="+secret)
+	req.Header.Add("Cookie", "b="+secret)
+	req.Header.Set("X-Api-Key", secret)
+	mdw.LogHandler(next).ServeHTTP(res, req)
+	out := buf.String()
+	are.True(strings.Contains(out, "req.header.user-agent=logm"))          // request user agent expected
+	are.True(strings.Contains(out, "req.header.authorization=***"))        // masked authorization expected
+	are.True(strings.Contains(out, "req.header.cookie=***"))               // masked cookie expected
+	are.True(strings.Contains(out, "req.header.content-length=5"))         // request content length expected
+	are.True(!strings.Contains(out, "x-api-key"))                          // unexpected API key
+	are.True(!strings.Contains(out, "referer"))                            // unexpected referer
+	are.True(strings.Contains(out, "resp.header.content-type=text/plain")) // response content type expected
+	are.True(strings.Contains(out, "resp.header.set-cookie=***"))          // masked response cookie expected
+	are.True(!strings.Contains(out, secret))                               // unexpected secret
+}
+
+func TestMiddleware_LogHandler_body(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		debug    bool
+		limit    int
+		redactor *logm.Redactor
+		reqType  string
+		reqBody  string
+		ctype    string
+		resp     []byte
+		contains []string
+		excludes []string
+	}{
+		"Default": {
+			debug:    true,
+			resp:     []byte(info),
+			excludes: []string{"level=DEBUG", "body"},
+		},
+		"Disabled debug": {
+			limit:    10,
+			resp:     []byte(info),
+			excludes: []string{"level=DEBUG", "body"},
+		},
+		"Text": {
+			debug:   true,
+			limit:   10,
+			reqType: "text/plain",
+			ctype:   "text/plain; charset=utf-8",
+			resp:    []byte(info),
+			contains: []string{
+				`level=DEBUG msg="200 POST /"`,
+				`req.body="{\"q\":\"worl" req.body_size=13 req.body_truncated=true`,
+				"resp.body=hello resp.body_size=5 trace.id=",
+			},
+		},
+		"UTF-8 truncated": {
+			debug: true,
+			limit: 5,
+			ctype: "text/plain; charset=utf-8",
+			resp:  []byte("hell€"),
+			contains: []string{
+				"resp.body=hell resp.body_size=7 resp.body_truncated=true",
+			},
+		},
+		"Redacted form": {
+			debug:    true,
+			limit:    100,
+			reqType:  "application/x-www-form-urlencoded",
+			reqBody:  "login=me&password=" + secret,
+			resp:     []byte(info),
+			contains: []string{`req.body="login=me&password=%2A%2A%2A"`},
+			excludes: []string{secret},
+		},
+		"Redacted JSON": {
+			debug:    true,
+			limit:    100,
+			redactor: logm.NewRedactor(logm.DefaultRedactRules()...),
+			reqBody:  `{"token":"` + secret + `"}`,
+			resp:     []byte(info),
+			contains: []string{`req.body="{\"token\":\"***\"}"`},
+			excludes: []string{secret},
+		},
+		"Redacted truncated JSON": {
+			debug:    true,
+			limit:    10,
+			redactor: logm.NewRedactor(logm.DefaultRedactRules()...),
+			reqBody:  `{"token":"` + secret + `"}`,
+			resp:     []byte(info),
+			contains: []string{"req.body_size=24 req.body_hash=sha256:"},
+			excludes: []string{"req.body="},
+		},
+		"Binary": {
+			debug: true,
+			limit: 10,
+			resp:  []byte{0x00, 0x01, 0x02},
+			contains: []string{
+				"resp.body_size=3 resp.body_hash=sha256:ae4b3280e56e2faf83f414a6e3dabe9d5fbe18976544c05fed121accb85b53fc",
+			},
+			excludes: []string{"resp.body="},
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.Copy(io.Discard, r.Body)
+					if tt.ctype != "" {
+						w.Header().Set("Content-Type", tt.ctype)
+					}
+					_, _ = w.Write(tt.resp)
+				})
+				buf = new(bytes.Buffer)
+				log = logm.DefaultLogger(name, buf)
+				res = httptest.NewRecorder()
+			)
+			if tt.debug {
+				log = logm.DebugLogger(name, buf)
+			}
+			if tt.reqBody == "" {
+				tt.reqBody = `{"q":"` + debug + `"}`
+			}
+			if tt.reqType == "" {
+				tt.reqType = "application/json"
+			}
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tt.reqBody))
+			req.Header.Set("Content-Type", tt.reqType)
+			body := req.Body
+			logm.Middleware{Logger: log, BodyLimit: tt.limit, Redactor: tt.redactor}.LogHandler(next).ServeHTTP(res, req)
+			are.Equal(body, req.Body)            // unexpected request modification
+			are.Equal(tt.resp, res.Body.Bytes()) // mismatch response body
+			out := buf.String()
+			for _, s := range tt.contains {
+				are.True(strings.Contains(out, s)) // missing content
+			}
+			for _, s := range tt.excludes {
+				are.True(!strings.Contains(out, s)) // unexpected content
+			}
+		})
+	}
+}
+
+func TestMiddleware_LogHandler_level(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		mdw    logm.Middleware
+		target string
+		code   int
+		out    string
+	}{
+		"Default":      {code: http.StatusOK, out: `level=INFO msg="200 GET /"`},
+		"Redirect":     {code: http.StatusFound, out: `level=INFO msg="302 GET /"`},
+		"Client error": {code: http.StatusNotFound, out: `level=WARN msg="404 GET /"`},
+		"Server error": {code: http.StatusBadGateway, out: `level=ERROR msg="502 GET /"`},
+		"Custom level": {
+			mdw: logm.Middleware{LevelFunc: func(code int) slog.Level {
+				return slog.LevelError
+			}},
+			code: http.StatusOK,
+			out:  `level=ERROR msg="200 GET /"`,
+		},
+		"Skipped path": {
+			mdw:    logm.Middleware{SkipPaths: []string{"/healthz", "/debug/*"}},
+			target: "healthz",
+			code:   http.StatusInternalServerError,
+		},
+		"Skipped pattern": {
+			mdw:    logm.Middleware{SkipPaths: []string{"/healthz", "/debug/*"}},
+			target: "debug/vars",
+			code:   http.StatusOK,
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.code)
+				})
+				buf = new(bytes.Buffer)
+				req = httptest.NewRequest(http.MethodGet, target+tt.target, nil)
+				res = httptest.NewRecorder()
+			)
+			tt.mdw.Logger = logm.DefaultLogger(name, buf)
+			tt.mdw.LogHandler(next).ServeHTTP(res, req)
+			are.Equal(tt.code, res.Code) // mismatch status code
+			if tt.out == "" {
+				are.Equal("", buf.String()) // unexpected log
+			} else {
+				are.True(strings.Contains(buf.String(), tt.out)) // mismatch log
+			}
+		})
+	}
+}
+
+func TestMiddleware_LogHandler_writer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Flusher", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are  = is.New(t)
+			next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(info))
+				_, ok := w.(http.Flusher)
+				are.True(ok)                                     // expected flusher
+				are.NoErr(http.NewResponseController(w).Flush()) // unexpected flush error
+				_, ok = w.(http.Pusher)
+				are.True(!ok) // unexpected pusher
+				_, ok = w.(http.Hijacker)
+				are.True(!ok) // unexpected hijacker
+			})
+			buf = new(bytes.Buffer)
+			res = httptest.NewRecorder()
+		)
+		logm.LogHandler(logm.DefaultLogger(name, buf), next).ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+		are.True(res.Flushed)                                   // expected flushed response
+		are.True(strings.Contains(buf.String(), "resp.size=5")) // response size expected
+	})
+
+	t.Run("Not flusher", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are  = is.New(t)
+			next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, ok := w.(http.Flusher)
+				are.True(!ok)                                                                    // unexpected flusher
+				are.True(errors.Is(http.NewResponseController(w).Flush(), http.ErrNotSupported)) // expected flush error
+				_, _ = w.Write([]byte(info))
+			})
+			buf = new(bytes.Buffer)
+			res = httptest.NewRecorder()
+		)
+		logm.LogHandler(logm.DefaultLogger(name, buf), next).ServeHTTP(
+			struct{ http.ResponseWriter }{res},
+			httptest.NewRequest(http.MethodGet, target, nil),
+		)
+		are.True(!res.Flushed)                                  // unexpected flushed response
+		are.True(strings.Contains(buf.String(), "resp.size=5")) // response size expected
+	})
+
+	t.Run("ReaderFrom", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are  = is.New(t)
+			next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, err := io.Copy(w, strings.NewReader(info))
+				are.NoErr(err) // unexpected copy error
+			})
+			buf = new(bytes.Buffer)
+			srv = httptest.NewServer(logm.LogHandler(logm.DefaultLogger(name, buf), next))
+		)
+		defer srv.Close()
+		res, err := http.Get(srv.URL) //nolint:noctx
+		are.NoErr(err)                // unexpected request error
+		defer func() { _ = res.Body.Close() }()
+		are.Equal(info, readAll(t, res))                        // mismatch response body
+		are.True(strings.Contains(buf.String(), "resp.size=5")) // response size expected
+	})
+
+	t.Run("Hijacker", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are  = is.New(t)
+			done = make(chan struct{})
+			next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c, rw, err := http.NewResponseController(w).Hijack()
+				are.NoErr(err) // unexpected hijack error
+				_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+				_ = rw.Flush()
+				_ = c.Close()
+			})
+			buf = new(bytes.Buffer)
+			hdl = logm.LogHandler(logm.DefaultLogger(name, buf), next)
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(done)
+				hdl.ServeHTTP(w, r)
+			}))
+		)
+		defer srv.Close()
+		res, err := http.Get(srv.URL) //nolint:noctx
+		are.NoErr(err)                // unexpected request error
+		_ = res.Body.Close()
+		are.Equal(http.StatusSwitchingProtocols, res.StatusCode) // mismatch status code
+		<-done
+		out := buf.String()
+		are.True(strings.Contains(out, `msg="hijacked GET /"`)) // hijacked message expected
+		are.True(strings.Contains(out, "resp.hijacked=true"))   // hijacked response expected
+	})
+}
+
+func TestMiddleware_LogHandler_status(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		next     http.HandlerFunc
+		contains []string
+		excludes []string
+	}{
+		"Default": {
+			next:     func(w http.ResponseWriter, r *http.Request) {},
+			contains: []string{"resp.status=200 resp.size=0 resp.header_written=false"},
+			excludes: []string{"resp.ttfb="},
+		},
+		"Implicit": {
+			next: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(info))
+				w.WriteHeader(http.StatusNotFound)
+			},
+			contains: []string{"resp.status=200 resp.size=5 resp.header_written=true resp.ttfb="},
+		},
+		"Superfluous": {
+			next: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			contains: []string{"resp.status=201 resp.size=0 resp.header_written=true resp.ttfb="},
+		},
+		"Informational": {
+			next: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusNoContent)
+			},
+			contains: []string{"resp.status=204 resp.size=0 resp.header_written=true"},
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				buf = new(bytes.Buffer)
+				req = httptest.NewRequest(http.MethodGet, target, nil)
+			)
+			logm.LogHandler(logm.DefaultLogger(name, buf), tt.next).ServeHTTP(httptest.NewRecorder(), req)
+			out := buf.String()
+			for _, s := range tt.contains {
+				are.True(strings.Contains(out, s)) // missing content
+			}
+			for _, s := range tt.excludes {
+				are.True(!strings.Contains(out, s)) // unexpected content
+			}
+		})
+	}
 }
 
 func TestTraceHandler(t *testing.T) {
@@ -65,6 +462,77 @@ func TestTraceHandler(t *testing.T) {
 	are.Equal("", res.Body.String())   // unexpected response content
 }
 
+func TestTraceHandler_propagation(t *testing.T) {
+	t.Parallel()
+	var (
+		are  = is.New(t)
+		ids  = make(chan string, 2)
+		down = httptest.NewServer(logm.TraceHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tc, _ := logm.TraceFromContext(r.Context())
+			ids <- tc.ID
+		})))
+		cli = &http.Client{Transport: logm.Transport{}}
+		up  = httptest.NewServer(logm.TraceHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tc, _ := logm.TraceFromContext(r.Context())
+			ids <- tc.ID
+			req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, down.URL, nil)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			res, err := cli.Do(req)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_ = res.Body.Close()
+		})))
+	)
+	defer down.Close()
+	defer up.Close()
+
+	res, err := http.Get(up.URL) //nolint:noctx
+	are.NoErr(err)               // unexpected response error
+	_ = res.Body.Close()
+	are.Equal(http.StatusOK, res.StatusCode) // unexpected response code
+	upID, downID := <-ids, <-ids
+	are.True(upID != "")    // expected trace ID
+	are.Equal(upID, downID) // same trace ID expected on both sides
+}
+
+func TestMiddleware_TraceHandler(t *testing.T) {
+	t.Parallel()
+	for desc, tc := range map[string]struct {
+		in  string
+		out string
+	}{
+		"Valid":    {in: " " + traceID + " ", out: traceID},
+		"Invalid":  {in: "<script>", out: "fixed"},
+		"Too long": {in: strings.Repeat("a", logm.MaxTraceIDLength+1), out: "fixed"},
+		"Blank":    {out: "fixed"},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				are  = is.New(t)
+				next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					tc, _ := logm.TraceFromContext(r.Context())
+					_, _ = w.Write([]byte(tc.ID))
+				})
+				mdw = logm.Middleware{IDGenerator: logm.IDGeneratorFunc(func() (string, error) {
+					return "fixed", nil
+				})}
+				req = httptest.NewRequest(http.MethodGet, target, nil)
+				res = httptest.NewRecorder()
+			)
+			req.Header.Set(logm.TraceIDHTTPHeader, tt.in)
+			mdw.TraceHandler(next).ServeHTTP(res, req)
+			are.Equal(tt.out, res.Body.String()) // mismatch trace ID
+		})
+	}
+}
+
 func TestRecoverHandler(t *testing.T) {
 	t.Parallel()
 
@@ -83,9 +551,11 @@ func TestRecoverHandler(t *testing.T) {
 		hdl.ServeHTTP(res, req)
 		are.Equal(http.StatusInternalServerError, res.Code) // unexpected response code
 		out := buf.String()
-		are.True(strings.Contains(out, "level=ERROR msg=earth"))      // unexpected error message
-		are.True(strings.Contains(out, `level=DEBUG msg="goroutine`)) // unexpected debug message
-		are.Equal("Internal Server Error\n", res.Body.String())       // unexpected response message
+		are.True(strings.Contains(out, "level=ERROR msg=earth"))                                        // unexpected error message
+		are.True(strings.Contains(out, "resp.status=500 resp.size=22 resp.partial=false"))              // mismatch response
+		are.True(strings.Contains(out, "stack.0.func=github.com/rvflash/logm_test.TestRecoverHandler")) // unexpected stack trace
+		are.True(!strings.Contains(out, "level=DEBUG"))                                                 // unexpected debug message
+		are.Equal("Internal Server Error\n", res.Body.String())                                         // unexpected response message
 	})
 
 	t.Run("Custom message", func(t *testing.T) {
@@ -103,8 +573,124 @@ func TestRecoverHandler(t *testing.T) {
 		hdl.ServeHTTP(res, req)
 		are.Equal(http.StatusInternalServerError, res.Code) // unexpected response code
 		out := buf.String()
-		are.True(strings.Contains(out, "level=ERROR msg=earth"))      // unexpected error message
-		are.True(strings.Contains(out, `level=DEBUG msg="goroutine`)) // unexpected debug message
-		are.Equal(intErr+"\n", res.Body.String())                     // unexpected response message
+		are.True(strings.Contains(out, "level=ERROR msg=earth")) // unexpected error message
+		are.True(strings.Contains(out, "stack.0.line="))         // unexpected stack trace
+		are.True(!strings.Contains(out, "level=DEBUG"))          // unexpected debug message
+		are.Equal(intErr+"\n", res.Body.String())                // unexpected response message
 	})
 }
+
+func TestRecoverHandler_committed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Partial response", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are  = is.New(t)
+			next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(info))
+				panic(warn)
+			})
+			buf = new(bytes.Buffer)
+			log = logm.DefaultLogger(name, buf)
+			hdl = logm.LogHandler(log, logm.RecoverHandler("", log, next))
+			req = httptest.NewRequest(http.MethodGet, target, nil)
+			res = httptest.NewRecorder()
+		)
+		hdl.ServeHTTP(res, req)
+		are.Equal(http.StatusOK, res.Code) // unexpected response code
+		are.Equal(info, res.Body.String()) // unexpected response message
+		out := buf.String()
+		are.True(strings.Contains(out, "level=ERROR msg=earth"))                         // unexpected error message
+		are.True(strings.Contains(out, "resp.status=200 resp.size=5 resp.partial=true")) // partial response expected
+		are.True(strings.Contains(out, `level=INFO msg="200 GET /"`))                    // request log expected
+	})
+
+	t.Run("Abort handler", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are  = is.New(t)
+			next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic(http.ErrAbortHandler)
+			})
+			buf = new(bytes.Buffer)
+			hdl = logm.RecoverHandler("", logm.DefaultLogger(name, buf), next)
+			req = httptest.NewRequest(http.MethodGet, target, nil)
+			res = httptest.NewRecorder()
+		)
+		defer func() {
+			are.Equal(http.ErrAbortHandler, recover()) // expected abort panic
+			are.Equal("", buf.String())                // unexpected log
+			are.Equal("", res.Body.String())           // unexpected response message
+		}()
+		hdl.ServeHTTP(res, req)
+	})
+}
+
+func TestMiddleware_RecoverHandler_trace(t *testing.T) {
+	t.Parallel()
+	var (
+		are  = is.New(t)
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(warn)
+		})
+		buf = new(bytes.Buffer)
+		mdw = logm.Middleware{Logger: logm.DefaultLogger(name, buf), PanicRenderer: logm.ProblemRenderer(intErr)}
+		res = httptest.NewRecorder()
+		p   logm.Problem
+	)
+	mdw.LogHandler(mdw.RecoverHandler(next)).ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+	are.NoErr(json.NewDecoder(res.Body).Decode(&p)) // unexpected problem document
+	are.True(p.TraceID != "")                       // missing trace ID
+	out := buf.String()
+	are.True(strings.Contains(out, "panic.id="+p.TraceID)) // same trace ID expected in the panic record
+	are.True(strings.Contains(out, "trace.id="+p.TraceID)) // same trace ID expected in the request record
+}
+
+func TestMiddleware_RecoverHandler(t *testing.T) {
+	t.Parallel()
+	for desc, tc := range map[string]struct {
+		render logm.PanicRenderer
+		ctype  string
+		body   string
+	}{
+		"Default": {
+			ctype: "text/plain; charset=utf-8",
+			body:  intErr + "\n",
+		},
+		"Custom": {
+			render: func(w http.ResponseWriter, r *http.Request, err error, t *logm.Trace) {
+				http.Error(w, err.Error()+" "+t.ID, http.StatusServiceUnavailable)
+			},
+			ctype: "text/plain; charset=utf-8",
+			body:  warn + " " + traceID + "\n",
+		},
+		"Problem": {
+			render: logm.ProblemRenderer(intErr),
+			ctype:  logm.ProblemContentType,
+			body: `{"type":"about:blank","title":"Internal Server Error","status":500,` +
+				`"detail":"` + intErr + `","instance":"/","trace_id":"` + traceID + `"}` + "\n",
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				are  = is.New(t)
+				next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					panic(warn)
+				})
+				buf = new(bytes.Buffer)
+				mdw = logm.Middleware{Logger: logm.DefaultLogger(name, buf), ErrorMessage: intErr, PanicRenderer: tt.render}
+				req = httptest.NewRequest(http.MethodGet, target, nil)
+				res = httptest.NewRecorder()
+			)
+			req.Header.Set(logm.TraceIDHTTPHeader, traceID)
+			mdw.RecoverHandler(next).ServeHTTP(res, req)
+			are.True(res.Code >= http.StatusInternalServerError)          // unexpected response code
+			are.Equal(tt.ctype, res.Header().Get("Content-Type"))         // unexpected content type
+			are.Equal(tt.body, res.Body.String())                         // unexpected response message
+			are.True(strings.Contains(buf.String(), "panic.id="+traceID)) // trace ID expected
+		})
+	}
+}
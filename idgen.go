@@ -0,0 +1,163 @@
+package logm
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	mrand "math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxTraceIDLength is the maximum length of an inbound trace identifier.
+const MaxTraceIDLength = 64
+
+// IDGenerator generates trace identifiers.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// IDGeneratorFunc is an adapter to use an ordinary function as IDGenerator.
+type IDGeneratorFunc func() (string, error)
+
+// NewID implements the IDGenerator interface.
+func (f IDGeneratorFunc) NewID() (string, error) {
+	return f()
+}
+
+// List of available trace identifier generators.
+var (
+	// UUIDv4Generator generates random UUID v4, the default generator.
+	UUIDv4Generator IDGenerator = IDGeneratorFunc(newUUIDv4)
+	// UUIDv7Generator generates time-ordered UUID v7.
+	UUIDv7Generator IDGenerator = IDGeneratorFunc(newUUIDv7)
+	// ULIDGenerator generates time-ordered ULID, encoded in Crockford's base32.
+	ULIDGenerator IDGenerator = IDGeneratorFunc(newULID)
+	// W3CGenerator generates random 16-byte hexadecimal identifiers, as expected by the W3C Trace Context.
+	W3CGenerator IDGenerator = IDGeneratorFunc(newW3CTraceID)
+	// FastGenerator generates 16-byte hexadecimal identifiers with a fast but non-cryptographic random source.
+	// It never fails, so it's also used as fallback when a generator fails.
+	FastGenerator IDGenerator = IDGeneratorFunc(newFastTraceID)
+)
+
+type idGenerator struct {
+	IDGenerator
+}
+
+var defaultIDGenerator atomic.Value
+
+func init() {
+	defaultIDGenerator.Store(idGenerator{UUIDv4Generator})
+}
+
+// SetIDGenerator defines the IDGenerator used by default to create the trace identifiers.
+// If g is nil, the UUIDv4Generator is restored.
+func SetIDGenerator(g IDGenerator) {
+	if g == nil {
+		g = UUIDv4Generator
+	}
+	defaultIDGenerator.Store(idGenerator{g})
+}
+
+// newTraceID returns a new trace identifier generated by g, or the default IDGenerator if nil.
+// If the generator fails, the FastGenerator is used.
+func newTraceID(g IDGenerator) string {
+	if g == nil {
+		g = defaultIDGenerator.Load().(idGenerator).IDGenerator
+	}
+	id, err := g.NewID()
+	if err != nil || id == "" {
+		id, _ = newFastTraceID()
+	}
+	return id
+}
+
+// ValidTraceID reports whether id is acceptable as an inbound trace identifier:
+// not blank, up to MaxTraceIDLength characters, only made of ASCII letters, digits, '-', '_' or '.'.
+func ValidTraceID(id string) bool {
+	if id == "" || len(id) > MaxTraceIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeTraceID returns the inbound trace identifier without the surrounding spaces, if valid.
+func normalizeTraceID(id string) (string, bool) {
+	id = strings.TrimSpace(id)
+	return id, ValidTraceID(id)
+}
+
+func newUUIDv4() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+func newUUIDv7() (string, error) {
+	var b uuid.UUID
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	putMillis(b[:6], time.Now())
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return b.String(), nil
+}
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func newULID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	putMillis(b[:6], time.Now())
+	var (
+		hi  = binary.BigEndian.Uint64(b[:8])
+		lo  = binary.BigEndian.Uint64(b[8:])
+		res [26]byte
+	)
+	for i := len(res) - 1; i >= 0; i-- {
+		res[i] = crockfordBase32[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(res[:]), nil
+}
+
+// putMillis writes the Unix time in milliseconds of t as a 48-bit big-endian value in b.
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+func newW3CTraceID() (string, error) {
+	b := make([]byte, traceIDHexLen/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[0] |= 1 // never all zeros
+	return hex.EncodeToString(b), nil
+}
+
+func newFastTraceID() (string, error) {
+	b := make([]byte, traceIDHexLen/2)
+	binary.BigEndian.PutUint64(b[:8], mrand.Uint64())   //nolint:gosec // non-cryptographic by design
+	binary.BigEndian.PutUint64(b[8:], mrand.Uint64()|1) //nolint:gosec // never all zeros
+	return hex.EncodeToString(b), nil
+}
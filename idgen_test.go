@@ -0,0 +1,88 @@
+package logm_test
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/rvflash/logm"
+)
+
+func TestIDGenerator(t *testing.T) {
+	t.Parallel()
+	for desc, tc := range map[string]struct {
+		gen logm.IDGenerator
+		out *regexp.Regexp
+	}{
+		"UUIDv4": {gen: logm.UUIDv4Generator, out: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		"UUIDv7": {gen: logm.UUIDv7Generator, out: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		"ULID":   {gen: logm.ULIDGenerator, out: regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+		"W3C":    {gen: logm.W3CGenerator, out: regexp.MustCompile(`^[0-9a-f]{32}$`)},
+		"Fast":   {gen: logm.FastGenerator, out: regexp.MustCompile(`^[0-9a-f]{32}$`)},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			are := is.New(t)
+			id1, err := tt.gen.NewID()
+			are.NoErr(err)                    // unexpected error
+			are.True(tt.out.MatchString(id1)) // mismatch format
+			are.True(logm.ValidTraceID(id1))  // invalid trace ID
+			id2, err := tt.gen.NewID()
+			are.NoErr(err)       // unexpected error
+			are.True(id1 != id2) // expected unique ID
+		})
+	}
+}
+
+func TestULIDGenerator(t *testing.T) {
+	t.Parallel()
+	var (
+		are    = is.New(t)
+		id1, _ = logm.ULIDGenerator.NewID()
+		id2, _ = logm.ULIDGenerator.NewID()
+	)
+	// The first 10 characters encode the time in milliseconds.
+	are.True(id1[:10] <= id2[:10]) // expected time-ordered IDs
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	// Not parallel: the generator is global.
+	are := is.New(t)
+	defer logm.SetIDGenerator(nil)
+
+	logm.SetIDGenerator(logm.IDGeneratorFunc(func() (string, error) {
+		return "fixed", nil
+	}))
+	are.Equal("fixed", logm.NewTrace().ID) // mismatch generator
+
+	logm.SetIDGenerator(logm.IDGeneratorFunc(func() (string, error) {
+		return "", errors.New("oops")
+	}))
+	are.Equal(32, len(logm.NewTrace().ID)) // fallback expected
+
+	logm.SetIDGenerator(nil)
+	are.Equal(36, len(logm.NewTrace().ID)) // UUID v4 expected
+}
+
+func TestValidTraceID(t *testing.T) {
+	t.Parallel()
+	for in, out := range map[string]bool{
+		"":                           false,
+		traceID:                      true,
+		w3cTraceID:                   true,
+		"01ARZ3NDEKTSV4RRFFQ69G5FAV": true,
+		"span_1.2":                   true,
+		"a b":                        false,
+		"<script>":                   false,
+		"é":                          false,
+		strings.Repeat("a", logm.MaxTraceIDLength):   true,
+		strings.Repeat("a", logm.MaxTraceIDLength+1): false,
+	} {
+		are := is.New(t)
+		are.Equal(out, logm.ValidTraceID(in)) // mismatch result
+	}
+}
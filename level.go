@@ -0,0 +1,101 @@
+package logm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Level is the minimum log level shared by the loggers built by DefaultLogger or by New without WithLevel.
+// Its zero value is INFO. It can be adjusted at runtime, see LevelHandler.
+var Level = new(slog.LevelVar)
+
+const (
+	maxLevelSize     = 32
+	levelRevertParam = "revert"
+)
+
+// LevelHandler returns an HTTP handler designed to read and set the level of this slog.LevelVar.
+// A GET request returns the current level, like `INFO`.
+// A PUT request sets the level with the one given in the request body, like `DEBUG` or `WARN+2`, and returns it.
+// An optional `revert` query parameter, like `?revert=10m`, automatically restores the previous level
+// after this time.Duration. Any new PUT request cancels a pending revert. While a revert is pending,
+// the previous level is the one set before the first reverted PUT request, never a temporary one.
+func LevelHandler(v *slog.LevelVar) http.Handler {
+	return &levelHandler{v: v}
+}
+
+type levelHandler struct {
+	v     *slog.LevelVar
+	mu    sync.Mutex
+	timer *time.Timer
+	// orig is the level to restore by the pending timer.
+	orig slog.Level
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		if err := h.set(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprintln(w, h.v.Level())
+}
+
+func (h *levelHandler) set(r *http.Request) error {
+	var revert time.Duration
+	if s := r.URL.Query().Get(levelRevertParam); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid %s duration: %q", levelRevertParam, s)
+		}
+		revert = d
+	}
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxLevelSize))
+	if err != nil {
+		return err
+	}
+	var level slog.Level
+	if err = level.UnmarshalText(bytes.TrimSpace(b)); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prev := h.v.Level()
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+		prev = h.orig
+	}
+	h.v.Set(level)
+	if revert > 0 {
+		h.orig = prev
+		var t *time.Timer
+		t = time.AfterFunc(revert, func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if h.timer != t {
+				// Cancelled by a newer request.
+				return
+			}
+			h.v.Set(h.orig)
+			h.timer = nil
+		})
+		h.timer = t
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package logm_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/rvflash/logm"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestLevel(t *testing.T) {
+	t.Parallel()
+	is.New(t).Equal(slog.LevelInfo, logm.Level.Level()) // mismatch default level
+}
+
+func TestLevelHandler(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		method string
+		target string
+		body   string
+		code   int
+		out    string
+		level  slog.Level
+	}{
+		"Default":        {method: http.MethodGet, code: http.StatusOK, out: "INFO\n"},
+		"Set":            {method: http.MethodPut, body: "debug\n", code: http.StatusOK, out: "DEBUG\n", level: slog.LevelDebug},
+		"Set offset":     {method: http.MethodPut, body: "WARN+2", code: http.StatusOK, out: "WARN+2\n", level: slog.LevelWarn + 2},
+		"Set and revert": {method: http.MethodPut, target: "?revert=1h", body: "ERROR", code: http.StatusOK, out: "ERROR\n", level: slog.LevelError},
+		"Invalid level":  {method: http.MethodPut, body: "oops", code: http.StatusBadRequest},
+		"Invalid revert": {method: http.MethodPut, target: "?revert=oops", body: "DEBUG", code: http.StatusBadRequest},
+		"Not allowed":    {method: http.MethodPost, body: "DEBUG", code: http.StatusMethodNotAllowed},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				v   = new(slog.LevelVar)
+				req = httptest.NewRequest(tt.method, target+tt.target, strings.NewReader(tt.body))
+				res = httptest.NewRecorder()
+			)
+			logm.LevelHandler(v).ServeHTTP(res, req)
+			are.Equal(tt.code, res.Code)   // mismatch status code
+			are.Equal(tt.level, v.Level()) // mismatch level
+			if tt.out != "" {
+				are.Equal(tt.out, res.Body.String()) // mismatch body
+			}
+		})
+	}
+}
+
+// waitLevel reports whether the level of v becomes level within a few seconds.
+func waitLevel(v *slog.LevelVar, level slog.Level) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if v.Level() == level {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLevelHandler_Revert(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		v   = new(slog.LevelVar)
+		hdl = logm.LevelHandler(v)
+	)
+	v.Set(slog.LevelWarn)
+	hdl.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, target+"?revert=500ms", strings.NewReader("DEBUG")))
+	are.Equal(slog.LevelDebug, v.Level())  // mismatch level
+	are.True(waitLevel(v, slog.LevelWarn)) // reverted level expected
+}
+
+func TestLevelHandler_RevertChained(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		v   = new(slog.LevelVar)
+		hdl = logm.LevelHandler(v)
+		put = func(target, level string) {
+			hdl.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, target, strings.NewReader(level)))
+		}
+	)
+	v.Set(slog.LevelInfo)
+	put(target+"?revert=10m", "DEBUG")
+	put(target+"?revert=1h", "WARN")
+	are.Equal(slog.LevelWarn, v.Level()) // mismatch level
+	put(target+"?revert=500ms", "ERROR")
+	are.Equal(slog.LevelError, v.Level())  // mismatch level
+	are.True(waitLevel(v, slog.LevelInfo)) // original level expected
+
+	// Whether the revert is cancelled or already done, the last level wins.
+	put(target+"?revert=10ms", "DEBUG")
+	put(target, "ERROR")
+	time.Sleep(50 * time.Millisecond)
+	are.Equal(slog.LevelError, v.Level()) // cancelled revert expected
+}
@@ -12,14 +12,15 @@ import (
 
 // DebugLogger returns a new instance of Logger dedicated to debug or test environment.
 // Debug messages are included and each message will include the application name and version.
-func DebugLogger(name string, w io.Writer) *slog.Logger {
-	return NewLogger(name, w, slog.LevelDebug)
+func DebugLogger(name string, w io.Writer, opts ...Option) *slog.Logger {
+	return NewLogger(name, w, slog.LevelDebug, opts...)
 }
 
 // DefaultLogger returns a new instance of Logger, ready to be use in production mode.
 // Debug messages are ignored and each message will include the application name and version.
-func DefaultLogger(name string, w io.Writer) *slog.Logger {
-	return NewLogger(name, w, slog.LevelInfo)
+// Its minimum log level is the shared Level, adjustable at runtime.
+func DefaultLogger(name string, w io.Writer, opts ...Option) *slog.Logger {
+	return New(name, append([]Option{WithWriter(w), WithLevel(Level)}, opts...)...)
 }
 
 // DiscardLogger is a logger doing anything. Useful for test purpose and default behavior.
@@ -29,17 +30,18 @@ func DiscardLogger() *slog.Logger {
 	return l
 }
 
+// New returns a new instance of Logger named name, configured by these options.
+// By default, the records are written on the standard error, formatted as logfmt
+// and the minimum log level is the shared Level, INFO unless adjusted. Each message will include the application name and version.
+func New(name string, opts ...Option) *slog.Logger {
+	return slog.New(newSettings(opts).handler(name))
+}
+
 // NewLogger returns a new instance of Logger where the level is the minimum log level to consider.
 // Each message will include the application name and version.
-func NewLogger(name string, w io.Writer, level slog.Level) *slog.Logger {
-	h := slog.HandlerOptions{
-		Level: level,
-	}
-	l := slog.New(h.NewTextHandler(w).WithAttrs([]slog.Attr{
-		slog.String(AppNameKey, name),
-		slog.String(AppVersionKey, vcsVersion()),
-	}))
-	return l
+// By default, the records are formatted as logfmt, see WithFormat to change it.
+func NewLogger(name string, w io.Writer, level slog.Level, opts ...Option) *slog.Logger {
+	return New(name, append([]Option{WithWriter(w), WithLevel(level)}, opts...)...)
 }
 
 // vcsVersion returns the VCS version available since go1.18 in build info.
@@ -61,10 +63,5 @@ func vcsVersion() string {
 //
 // It offers a useful interface to be called in defer statement.
 func TimeElapsed(ctx context.Context, l *slog.Logger, level slog.Level, msg string, attrs ...slog.Attr) func() {
-	t := NewTraceFromContext(ctx)
-	t.Start()
-	return func() {
-		t.End()
-		l.LogAttrs(ctx, level, msg, append(attrs, t.LogAttr())...)
-	}
+	return NewTraceFromContext(ctx).TimeElapsed(ctx, l, level, msg, attrs...)
 }
@@ -3,6 +3,7 @@ package logm_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -44,6 +45,22 @@ func TestDiscardLogger(t *testing.T) {
 	is.New(t).True(l != nil)
 }
 
+func TestNew(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		l   = logm.New(name, logm.WithWriter(buf))
+	)
+	l.Info(info)
+	l.Debug(debug)
+
+	out := buf.String()
+	are.True(strings.Contains(out, name))   // missing app message
+	are.True(strings.Contains(out, info))   // missing log message
+	are.True(!strings.Contains(out, debug)) // unexpected debug message
+}
+
 func TestNewLogger(t *testing.T) {
 	t.Parallel()
 	var (
@@ -92,7 +109,31 @@ func TestTimeElapsed(t *testing.T) {
 		out := buf.String()
 		are.True(strings.Contains(out, info))                     // missing log message
 		are.True(strings.Contains(out, "trace.time_elapsed_ms=")) // missing time elapsed
+		are.True(strings.Contains(out, "trace.duration_ms="))     // missing duration
 	})
 	defer logm.TimeElapsed(tc.NewContext(context.Background()), l, slog.LevelInfo, info)()
 	time.Sleep(time.Millisecond)
 }
+
+func TestTrace_TimeElapsed(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		tc  = logm.Trace{ID: traceID}
+	)
+	func() {
+		defer tc.TimeElapsed(context.Background(), logm.DebugLogger(name, buf), slog.LevelInfo, info)()
+		tc.SetAttr("db.table", "users")
+		tc.AddEvent("query", slog.Int("rows", 2))
+		tc.SetStatus(errors.New(intErr))
+	}()
+	out := buf.String()
+	are.True(strings.Contains(out, "trace.id="+traceID))          // missing trace ID
+	are.True(strings.Contains(out, "trace.duration_ms="))         // missing duration
+	are.True(strings.Contains(out, "trace.attrs.db.table=users")) // missing attribute
+	are.True(strings.Contains(out, "trace.events.0.name=query"))  // missing event
+	are.True(strings.Contains(out, "trace.events.0.rows=2"))      // missing event attribute
+	are.True(strings.Contains(out, "trace.status=error"))         // missing status
+	are.True(strings.Contains(out, `trace.error="`+intErr+`"`))   // missing error
+}
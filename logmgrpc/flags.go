@@ -0,0 +1,13 @@
+package logmgrpc
+
+// List of standard key to use to qualify gRPC structured log.
+const (
+	// GRPCKey is the gRPC call name in structured log.
+	GRPCKey = "grpc"
+	// GRPCMethodKey is the gRPC full method name in structured log.
+	GRPCMethodKey = "method"
+	// GRPCCodeKey is the gRPC status code in structured log.
+	GRPCCodeKey = "code"
+	// GRPCStreamKey reports whether the gRPC call is a stream in structured log.
+	GRPCStreamKey = "stream"
+)
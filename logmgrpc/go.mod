@@ -0,0 +1,21 @@
+module github.com/rvflash/logm/logmgrpc
+
+go 1.20
+
+require (
+	github.com/matryer/is v1.4.1
+	github.com/rvflash/logm v0.1.0
+	golang.org/x/exp v0.0.0-20230315142452-642cacee5cc0
+	google.golang.org/grpc v1.59.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/google/uuid v1.3.1 // indirect
+	github.com/natefinch/lumberjack v2.0.0+incompatible // indirect
+	golang.org/x/net v0.14.0 // indirect
+	golang.org/x/sys v0.11.0 // indirect
+	golang.org/x/text v0.12.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
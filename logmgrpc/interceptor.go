@@ -0,0 +1,316 @@
+// Package logmgrpc provides gRPC interceptors to deal with logs and trace context.
+package logmgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/rvflash/logm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"golang.org/x/exp/slog"
+)
+
+// Interceptor provides some standard gRPC interceptors to deal with logs.
+// It is the gRPC counterpart of logm.Middleware.
+// StackDepth is the maximum number of frames of the stack trace logged on panic, by default logm.DefaultStackDepth.
+// IDGenerator generates the identifiers of the new traces, by default the one defined by logm.SetIDGenerator.
+type Interceptor struct {
+	Logger       *slog.Logger
+	IDGenerator  logm.IDGenerator
+	ErrorMessage string
+	StackDepth   int
+}
+
+func init() {
+	// Trims the interceptors of the panic stack traces.
+	pkg := reflect.TypeOf(Interceptor{}).PkgPath()
+	logm.RegisterWrapperFrames(
+		pkg+".Interceptor.StreamServerLog",
+		pkg+".Interceptor.StreamServerRecover",
+		pkg+".Interceptor.StreamServerTrace",
+		pkg+".Interceptor.UnaryServerLog",
+		pkg+".Interceptor.UnaryServerRecover",
+		pkg+".Interceptor.UnaryServerTrace",
+		pkg+".StreamServerTrace",
+		pkg+".UnaryServerTrace",
+	)
+}
+
+// UnaryServerLog is a gRPC unary server interceptor designed to log every call.
+func (i Interceptor) UnaryServerLog(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	t := i.newTraceFromIncomingContext(ctx)
+	t.Start()
+	resp, err := handler(ctx, req)
+	t.End()
+	i.log(ctx, info.FullMethod, false, err, t)
+	return resp, err
+}
+
+// StreamServerLog is a gRPC stream server interceptor designed to log every stream.
+func (i Interceptor) StreamServerLog(
+	srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	t := i.newTraceFromIncomingContext(ss.Context())
+	t.Start()
+	err := handler(srv, ss)
+	t.End()
+	i.log(ss.Context(), info.FullMethod, true, err, t)
+	return err
+}
+
+// UnaryServerRecover is a gRPC unary server interceptor designed to recover on panic,
+// log the error and its stack trace. The panic is returned as an internal error.
+// If no error message is provided, we used the default internal error message.
+func (i Interceptor) UnaryServerRecover(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (resp any, err error) {
+	defer func() {
+		if pr := recover(); pr != nil {
+			err = i.recoverPanic(ctx, info.FullMethod, pr)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// StreamServerRecover is a gRPC stream server interceptor designed to recover on panic,
+// log the error and its stack trace. The panic is returned as an internal error.
+// If no error message is provided, we used the default internal error message.
+func (i Interceptor) StreamServerRecover(
+	srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) (err error) {
+	defer func() {
+		if pr := recover(); pr != nil {
+			err = i.recoverPanic(ss.Context(), info.FullMethod, pr)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// UnaryClient is a gRPC unary client interceptor designed to propagate the trace context
+// of the context through the outgoing metadata and to log every call.
+func (i Interceptor) UnaryClient(
+	ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	t := i.newTraceFromContext(ctx)
+	t.Start()
+	err := invoker(newOutgoingContext(ctx, t), method, req, reply, cc, opts...)
+	t.End()
+	i.log(ctx, method, false, err, t)
+	return err
+}
+
+// StreamClient is a gRPC stream client interceptor designed to propagate the trace context
+// of the context through the outgoing metadata and to log every stream once ended.
+// A stream ends on its first receive error, after its single response for a client-streaming call,
+// on a send error or when the context is done.
+func (i Interceptor) StreamClient(
+	ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	t := i.newTraceFromContext(ctx)
+	t.Start()
+	cs, err := streamer(newOutgoingContext(ctx, t), desc, cc, method, opts...)
+	if err != nil {
+		t.End()
+		i.log(ctx, method, true, err, t)
+		return nil, err
+	}
+	s := &clientStream{
+		ClientStream:  cs,
+		serverStreams: desc.ServerStreams,
+		stop:          make(chan struct{}),
+		done: func(err error) {
+			t.End()
+			i.log(ctx, method, true, err, t)
+		},
+	}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.end(status.FromContextError(ctx.Err()).Err())
+			case <-s.stop:
+			}
+		}()
+	}
+	return s, nil
+}
+
+func (i Interceptor) log(ctx context.Context, method string, stream bool, err error, t *logm.Trace) {
+	if i.Logger == nil {
+		return
+	}
+	code := status.Code(err)
+	i.Logger.LogAttrs(ctx, slog.LevelInfo,
+		fmt.Sprintf("%s %s", code, method),
+		slog.Group(GRPCKey,
+			slog.String(GRPCMethodKey, method),
+			slog.String(GRPCCodeKey, code.String()),
+			slog.Bool(GRPCStreamKey, stream),
+		),
+		t.LogAttr(),
+	)
+}
+
+func (i Interceptor) recoverPanic(ctx context.Context, method string, pr any) error {
+	var (
+		err = logm.PanicError(pr)
+		t   = i.newTraceFromIncomingContext(ctx)
+		rc  = slog.Group(GRPCKey, slog.String(GRPCMethodKey, method))
+	)
+	if i.Logger != nil {
+		i.Logger.Error(err.Error(), logm.PanicKey, t, rc, logm.StackKey, logm.NewPanicStack(i.StackDepth))
+	}
+	msg := i.ErrorMessage
+	if msg == "" {
+		msg = codes.Internal.String()
+	}
+	return status.Error(codes.Internal, msg)
+}
+
+// UnaryServerTrace is a gRPC unary server interceptor designed to share the trace context in the context.
+// Without trace in the incoming metadata, a new one is generated by the IDGenerator.
+func (i Interceptor) UnaryServerTrace(
+	ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	return handler(i.newTraceContext(ctx), req)
+}
+
+// StreamServerTrace is a gRPC stream server interceptor designed to share the trace context in the stream context.
+// Without trace in the incoming metadata, a new one is generated by the IDGenerator.
+func (i Interceptor) StreamServerTrace(
+	srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: i.newTraceContext(ss.Context())})
+}
+
+// UnaryServerTrace is a gRPC unary server interceptor designed to share the trace context in the context.
+// See Interceptor.UnaryServerTrace to use a specific IDGenerator.
+func UnaryServerTrace(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return Interceptor{}.UnaryServerTrace(ctx, req, info, handler)
+}
+
+// StreamServerTrace is a gRPC stream server interceptor designed to share the trace context in the stream context.
+// See Interceptor.StreamServerTrace to use a specific IDGenerator.
+func StreamServerTrace(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return Interceptor{}.StreamServerTrace(srv, ss, info, handler)
+}
+
+// newTraceContext creates a new trace span based on the incoming metadata and shares it
+// through the context and the incoming metadata.
+func (i Interceptor) newTraceContext(ctx context.Context) context.Context {
+	t := i.newTraceFromIncomingContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	return metadata.NewIncomingContext(t.NewContext(ctx), setMetadata(md.Copy(), t))
+}
+
+// newTraceFromIncomingContext returns a new Trace based on the incoming metadata,
+// using the same headers as the HTTP requests.
+func (i Interceptor) newTraceFromIncomingContext(ctx context.Context) *logm.Trace {
+	md, _ := metadata.FromIncomingContext(ctx)
+	h := make(http.Header, md.Len())
+	for k, v := range md {
+		h[http.CanonicalHeaderKey(k)] = v
+	}
+	if t, ok := logm.TraceFromHTTPRequest(&http.Request{Header: h}); ok {
+		return t.NewSpan()
+	}
+	return logm.NewTraceWithIDGenerator(i.IDGenerator)
+}
+
+// newTraceFromContext returns a new span of the trace of the context, if any, otherwise a new Trace.
+func (i Interceptor) newTraceFromContext(ctx context.Context) *logm.Trace {
+	if t, ok := logm.TraceFromContext(ctx); ok {
+		return t.NewSpan()
+	}
+	return logm.NewTraceWithIDGenerator(i.IDGenerator)
+}
+
+func newOutgoingContext(ctx context.Context, t *logm.Trace) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, setMetadata(md.Copy(), t))
+}
+
+func setMetadata(md metadata.MD, t *logm.Trace) metadata.MD {
+	h := make(http.Header)
+	t.SetHTTPHeader(h)
+	for _, k := range []string{logm.TraceIDHTTPHeader, logm.TraceParentHTTPHeader, logm.TraceStateHTTPHeader} {
+		if v := h.Values(k); len(v) > 0 {
+			md.Set(k, v...)
+		} else {
+			md.Delete(k)
+		}
+	}
+	return md
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx
+}
+
+// Context overrides the stream context.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	done          func(err error)
+	stop          chan struct{}
+	once          sync.Once
+}
+
+// CloseSend wraps the client stream to detect its end on error.
+func (s *clientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.end(err)
+	}
+	return err
+}
+
+// RecvMsg wraps the client stream to detect its end.
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case errors.Is(err, io.EOF):
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.serverStreams:
+		// A client-streaming call ends with its single response.
+		s.end(nil)
+	}
+	return err
+}
+
+// SendMsg wraps the client stream to detect its end on error.
+// On io.EOF, the stream status is returned by RecvMsg.
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		close(s.stop)
+		s.done(err)
+	})
+}
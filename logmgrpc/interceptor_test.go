@@ -0,0 +1,323 @@
+package logmgrpc_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/rvflash/logm"
+	"github.com/rvflash/logm/logmgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	name        = "app"
+	method      = "/logm.Test/Call"
+	w3cTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	w3cSpanID   = "00f067aa0ba902b7"
+	traceParent = "00-" + w3cTraceID + "-" + w3cSpanID + "-01"
+	intErr      = "oops I did it again"
+)
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func incomingContext() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", traceParent))
+}
+
+func TestInterceptor_UnaryServerLog(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		itc = logmgrpc.Interceptor{Logger: logm.DefaultLogger(name, buf)}
+	)
+	_, err := itc.UnaryServerLog(incomingContext(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req any) (any, error) {
+			return nil, status.Error(codes.NotFound, intErr)
+		},
+	)
+	are.Equal(codes.NotFound, status.Code(err)) // mismatch code
+	out := buf.String()
+	are.True(strings.Contains(out, "NotFound "+method))                // message expected
+	are.True(strings.Contains(out, "grpc.method="+method))             // method expected
+	are.True(strings.Contains(out, "grpc.code=NotFound"))              // code expected
+	are.True(strings.Contains(out, "grpc.stream=false"))               // stream expected
+	are.True(strings.Contains(out, "trace.id="+w3cTraceID))            // trace ID expected
+	are.True(strings.Contains(out, "trace.parent_span_id="+w3cSpanID)) // parent span ID expected
+	are.True(strings.Contains(out, "trace.time_elapsed_ms="))          // time elapsed expected
+}
+
+func TestInterceptor_StreamServerLog(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		itc = logmgrpc.Interceptor{Logger: logm.DefaultLogger(name, buf)}
+	)
+	err := itc.StreamServerLog(nil, serverStream{ctx: incomingContext()}, &grpc.StreamServerInfo{FullMethod: method},
+		func(srv any, stream grpc.ServerStream) error {
+			return nil
+		},
+	)
+	are.NoErr(err) // unexpected error
+	out := buf.String()
+	are.True(strings.Contains(out, "OK "+method))           // message expected
+	are.True(strings.Contains(out, "grpc.stream=true"))     // stream expected
+	are.True(strings.Contains(out, "trace.id="+w3cTraceID)) // trace ID expected
+}
+
+func TestInterceptor_UnaryServerRecover(t *testing.T) {
+	t.Parallel()
+
+	for desc, tc := range map[string]struct {
+		msg string
+		out string
+	}{
+		"Default message": {out: codes.Internal.String()},
+		"Custom message":  {msg: intErr, out: intErr},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				are = is.New(t)
+				buf = new(bytes.Buffer)
+				itc = logmgrpc.Interceptor{Logger: logm.DebugLogger(name, buf), ErrorMessage: tt.msg}
+			)
+			_, err := itc.UnaryServerRecover(incomingContext(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+				func(ctx context.Context, req any) (any, error) {
+					panic("earth")
+				},
+			)
+			are.Equal(codes.Internal, status.Code(err))      // mismatch code
+			are.Equal(tt.out, status.Convert(err).Message()) // mismatch message
+			out := buf.String()
+			are.True(strings.Contains(out, "level=ERROR msg=earth")) // unexpected error message
+			are.True(strings.Contains(out, "stack.0.func="))         // unexpected stack trace
+			are.True(!strings.Contains(out, "Interceptor.Unary"))    // unexpected interceptor frame
+			are.True(!strings.Contains(out, "level=DEBUG"))          // unexpected debug message
+			are.True(strings.Contains(out, "panic.id="+w3cTraceID))  // trace ID expected
+		})
+	}
+}
+
+func TestInterceptor_StreamServerRecover(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		itc = logmgrpc.Interceptor{Logger: logm.DebugLogger(name, buf)}
+	)
+	err := itc.StreamServerRecover(nil, serverStream{ctx: incomingContext()}, &grpc.StreamServerInfo{FullMethod: method},
+		func(srv any, stream grpc.ServerStream) error {
+			panic("earth")
+		},
+	)
+	are.Equal(codes.Internal, status.Code(err))                       // mismatch code
+	are.True(strings.Contains(buf.String(), "level=ERROR msg=earth")) // unexpected error message
+}
+
+func TestUnaryServerTrace(t *testing.T) {
+	t.Parallel()
+	are := is.New(t)
+	_, err := logmgrpc.UnaryServerTrace(incomingContext(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req any) (any, error) {
+			tc := logm.NewTraceFromContext(ctx)
+			are.Equal(w3cTraceID, tc.ID) // mismatch trace ID
+			md, _ := metadata.FromIncomingContext(ctx)
+			p, err := logm.ParseTraceParent(strings.Join(md.Get("traceparent"), ""))
+			are.NoErr(err)                       // invalid traceparent
+			are.Equal(tc.ParentSpanID, p.SpanID) // mismatch span ID
+			return nil, nil
+		},
+	)
+	are.NoErr(err) // unexpected error
+}
+
+func TestStreamServerTrace(t *testing.T) {
+	t.Parallel()
+	are := is.New(t)
+	err := logmgrpc.StreamServerTrace(nil, serverStream{ctx: incomingContext()}, &grpc.StreamServerInfo{FullMethod: method},
+		func(srv any, stream grpc.ServerStream) error {
+			tc := logm.NewTraceFromContext(stream.Context())
+			are.Equal(w3cTraceID, tc.ID) // mismatch trace ID
+			return nil
+		},
+	)
+	are.NoErr(err) // unexpected error
+}
+
+func TestInterceptor_UnaryClient(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		itc = logmgrpc.Interceptor{Logger: logm.DefaultLogger(name, buf)}
+		tc  = logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID, Flags: 1}
+		ctx = tc.NewContext(context.Background())
+	)
+	err := itc.UnaryClient(ctx, method, nil, nil, nil,
+		func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			p, err := logm.ParseTraceParent(strings.Join(md.Get("traceparent"), ""))
+			are.NoErr(err)                  // invalid traceparent
+			are.Equal(w3cTraceID, p.ID)     // mismatch trace ID
+			are.True(p.SpanID != w3cSpanID) // expected client span
+			return nil
+		},
+	)
+	are.NoErr(err) // unexpected error
+	out := buf.String()
+	are.True(strings.Contains(out, "OK "+method))                      // message expected
+	are.True(strings.Contains(out, "trace.parent_span_id="+w3cSpanID)) // parent span ID expected
+}
+
+func TestInterceptor_IDGenerator(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		itc = logmgrpc.Interceptor{
+			Logger: logm.DefaultLogger(name, buf),
+			IDGenerator: logm.IDGeneratorFunc(func() (string, error) {
+				return "fixed", nil
+			}),
+		}
+	)
+	_, err := itc.UnaryServerTrace(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, req any) (any, error) {
+			tc, ok := logm.TraceFromContext(ctx)
+			are.True(ok)              // expected trace
+			are.Equal("fixed", tc.ID) // mismatch server trace ID
+			return nil, nil
+		},
+	)
+	are.NoErr(err) // unexpected server error
+	err = itc.UnaryClient(context.Background(), method, nil, nil, nil,
+		func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		},
+	)
+	are.NoErr(err)                                             // unexpected client error
+	are.True(strings.Contains(buf.String(), "trace.id=fixed")) // mismatch client trace ID
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	recv []error
+}
+
+func (s *clientStream) CloseSend() error {
+	return nil
+}
+
+func (s *clientStream) SendMsg(any) error {
+	return nil
+}
+
+func (s *clientStream) RecvMsg(any) error {
+	if len(s.recv) == 0 {
+		return io.EOF
+	}
+	err := s.recv[0]
+	s.recv = s.recv[1:]
+	return err
+}
+
+// chanWriter sends each record on its channel, to wait for records logged asynchronously.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestInterceptor_StreamClient(t *testing.T) {
+	t.Parallel()
+	for desc, tc := range map[string]struct {
+		desc   *grpc.StreamDesc
+		recv   []error
+		cancel bool
+		out    string
+	}{
+		"Client streaming": {
+			desc: &grpc.StreamDesc{ClientStreams: true},
+			recv: []error{nil},
+			out:  "OK " + method,
+		},
+		"Server streaming": {
+			desc: &grpc.StreamDesc{ServerStreams: true},
+			recv: []error{nil, nil},
+			out:  "OK " + method,
+		},
+		"Server streaming error": {
+			desc: &grpc.StreamDesc{ServerStreams: true},
+			recv: []error{nil, status.Error(codes.Unavailable, intErr)},
+			out:  "Unavailable " + method,
+		},
+		"Canceled": {
+			desc:   &grpc.StreamDesc{ServerStreams: true},
+			cancel: true,
+			out:    "Canceled " + method,
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				are         = is.New(t)
+				logs        = make(chanWriter, 2)
+				itc         = logmgrpc.Interceptor{Logger: logm.DefaultLogger(name, logs)}
+				tr          = logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID, Flags: 1}
+				ctx, cancel = context.WithCancel(tr.NewContext(context.Background()))
+			)
+			defer cancel()
+			cs, err := itc.StreamClient(ctx, tt.desc, nil, method,
+				func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+					opts ...grpc.CallOption,
+				) (grpc.ClientStream, error) {
+					md, _ := metadata.FromOutgoingContext(ctx)
+					p, err := logm.ParseTraceParent(strings.Join(md.Get("traceparent"), ""))
+					are.NoErr(err)              // invalid traceparent
+					are.Equal(w3cTraceID, p.ID) // mismatch trace ID
+					return &clientStream{recv: tt.recv}, nil
+				},
+			)
+			are.NoErr(err) // unexpected error
+			if tt.cancel {
+				cancel()
+			} else {
+				are.NoErr(cs.SendMsg(nil)) // unexpected send error
+				are.NoErr(cs.CloseSend())  // unexpected close error
+				// Like CloseAndRecv, a client-streaming call receives its single response.
+				err = cs.RecvMsg(nil)
+				for err == nil && tt.desc.ServerStreams {
+					err = cs.RecvMsg(nil)
+				}
+			}
+			out := <-logs
+			are.True(strings.Contains(out, tt.out))                 // message expected
+			are.True(strings.Contains(out, "grpc.stream=true"))     // stream expected
+			are.True(strings.Contains(out, "trace.id="+w3cTraceID)) // trace ID expected
+			select {
+			case out = <-logs:
+				t.Fatalf("unexpected second record: %s", out)
+			default:
+			}
+		})
+	}
+}
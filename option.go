@@ -0,0 +1,190 @@
+package logm
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Format is the output format of a logger.
+type Format uint8
+
+// List of supported formats.
+const (
+	// TextFormat outputs each record as a line of key=value pairs, named logfmt. It's the default format.
+	TextFormat Format = iota
+	// JSONFormat outputs each record as a line-delimited JSON object.
+	JSONFormat
+)
+
+// Option is a setting of the logger.
+type Option func(*settings)
+
+// WithAddSource adds to each record the source code position of the log statement.
+func WithAddSource() Option {
+	return func(s *settings) {
+		s.addSource = true
+	}
+}
+
+// WithAttrs adds these static attributes to each record, after the application name and version.
+func WithAttrs(attrs ...slog.Attr) Option {
+	return func(s *settings) {
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// WithComponentLevels defines the minimum log levels by component, overriding the level of the logger.
+// See NewComponentHandler for more details.
+func WithComponentLevels(levels *ComponentLevels) Option {
+	return func(s *settings) {
+		s.componentLevels = levels
+	}
+}
+
+// WithFormat defines the output format of the logger.
+// Whatever the format, the records have the same attributes.
+func WithFormat(f Format) Option {
+	return func(s *settings) {
+		s.format = f
+	}
+}
+
+// WithLevel defines the minimum log level to consider. By default, it's the shared Level.
+// Use a slog.LevelVar to adjust it dynamically.
+func WithLevel(level slog.Leveler) Option {
+	return func(s *settings) {
+		s.level = level
+	}
+}
+
+// WithRedactor masks the value of the sensitive attributes with this Redactor.
+func WithRedactor(r *Redactor) Option {
+	return WithReplaceAttr(r.ReplaceAttr)
+}
+
+// WithReplaceAttr defines a function to rewrite each non-group attribute before it is logged.
+// See slog.HandlerOptions for more details. Successive functions are applied in order.
+func WithReplaceAttr(f func(groups []string, a slog.Attr) slog.Attr) Option {
+	return func(s *settings) {
+		s.replaceAttr = chainReplaceAttr(s.replaceAttr, f)
+	}
+}
+
+// WithSampling samples the records by level and message: during each interval,
+// the first ones are all logged, then only 1 in thereafter. See NewSamplingHandler for more details.
+func WithSampling(first, thereafter int, interval time.Duration) Option {
+	return func(s *settings) {
+		s.sampling = &samplingSettings{first: first, thereafter: thereafter, interval: interval}
+	}
+}
+
+// WithTimeFormat defines the layout used to format the time of each record. See time.Layout.
+// By default, the time is formatted with the RFC 3339 format with millisecond precision.
+func WithTimeFormat(layout string) Option {
+	return func(s *settings) {
+		s.timeFormat = layout
+	}
+}
+
+// WithVersion overrides the version of the application, by default the VCS revision of the build.
+func WithVersion(version string) Option {
+	return func(s *settings) {
+		s.version = version
+	}
+}
+
+// WithWriter defines the destination of the records. By default, it's the standard error.
+func WithWriter(w io.Writer) Option {
+	return func(s *settings) {
+		s.writer = w
+	}
+}
+
+type settings struct {
+	writer          io.Writer
+	level           slog.Leveler
+	componentLevels *ComponentLevels
+	sampling        *samplingSettings
+	replaceAttr     func(groups []string, a slog.Attr) slog.Attr
+	attrs           []slog.Attr
+	timeFormat      string
+	version         string
+	format          Format
+	addSource       bool
+}
+
+func newSettings(opts []Option) *settings {
+	s := &settings{
+		writer:  os.Stderr,
+		level:   Level,
+		version: vcsVersion(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *settings) handler(name string) slog.Handler {
+	o := slog.HandlerOptions{
+		AddSource:   s.addSource,
+		Level:       s.level,
+		ReplaceAttr: s.replaceAttr,
+	}
+	if s.timeFormat != "" {
+		o.ReplaceAttr = chainReplaceAttr(formatTime(s.timeFormat), s.replaceAttr)
+	}
+	var h slog.Handler
+	switch s.format {
+	case JSONFormat:
+		h = o.NewJSONHandler(s.writer)
+	default:
+		h = o.NewTextHandler(s.writer)
+	}
+	h = NewContextHandler(h)
+	if s.componentLevels != nil {
+		h = NewComponentHandler(h, s.componentLevels)
+	}
+	h = h.WithAttrs(append([]slog.Attr{
+		slog.String(AppNameKey, name),
+		slog.String(AppVersionKey, s.version),
+	}, s.attrs...))
+	if s.sampling != nil {
+		h = NewSamplingHandler(h, s.sampling.first, s.sampling.thereafter, s.sampling.interval)
+	}
+	return h
+}
+
+type samplingSettings struct {
+	first      int
+	thereafter int
+	interval   time.Duration
+}
+
+func chainReplaceAttr(f, g func(groups []string, a slog.Attr) slog.Attr) func(groups []string, a slog.Attr) slog.Attr {
+	switch {
+	case f == nil:
+		return g
+	case g == nil:
+		return f
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		a = f(groups, a)
+		if a.Key == "" {
+			return a
+		}
+		return g(groups, a)
+	}
+}
+
+func formatTime(layout string) func(groups []string, a slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime {
+			return slog.String(slog.TimeKey, a.Value.Time().Format(layout))
+		}
+		return a
+	}
+}
@@ -0,0 +1,126 @@
+package logm_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/rvflash/logm"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestOption(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		in       []logm.Option
+		contains []string
+		excludes []string
+	}{
+		"Default": {
+			contains: []string{"level=INFO msg=" + info, "app=" + name, "version="},
+			excludes: []string{"source="},
+		},
+		"Add source": {
+			in:       []logm.Option{logm.WithAddSource()},
+			contains: []string{"source="},
+		},
+		"Attrs": {
+			in:       []logm.Option{logm.WithAttrs(slog.String("env", "test")), logm.WithAttrs(slog.Int("pid", 1))},
+			contains: []string{"version= env=test pid=1"},
+		},
+		"Level": {
+			in:       []logm.Option{logm.WithLevel(slog.LevelWarn)},
+			excludes: []string{info},
+		},
+		"Replace attr": {
+			in: []logm.Option{
+				logm.WithReplaceAttr(func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.LevelKey {
+						return slog.Attr{}
+					}
+					return a
+				}),
+				logm.WithReplaceAttr(func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == logm.AppNameKey {
+						a.Value = slog.StringValue(debug)
+					}
+					return a
+				}),
+			},
+			contains: []string{"msg=" + info, "app=" + debug},
+			excludes: []string{"level="},
+		},
+		"Time format": {
+			in:       []logm.Option{logm.WithTimeFormat("now")},
+			contains: []string{"time=now level=INFO"},
+		},
+		"Version": {
+			in:       []logm.Option{logm.WithVersion("v1.0.0")},
+			contains: []string{"version=v1.0.0"},
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			buf := new(bytes.Buffer)
+			logm.New(name, append(tt.in, logm.WithWriter(buf))...).Info(info)
+			out := buf.String()
+			for _, s := range tt.contains {
+				are.True(strings.Contains(out, s)) // missing content
+			}
+			for _, s := range tt.excludes {
+				are.True(!strings.Contains(out, s)) // unexpected content
+			}
+		})
+	}
+}
+
+func TestWithFormat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Text", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are = is.New(t)
+			buf = new(bytes.Buffer)
+			tc  = logm.Trace{ID: traceID}
+			l   = logm.DefaultLogger(name, buf, logm.WithFormat(logm.TextFormat))
+		)
+		l.Info(info, tc.LogAttr())
+		out := buf.String()
+		are.True(strings.Contains(out, "msg="+info))         // missing message
+		are.True(strings.Contains(out, "app="+name))         // missing app name
+		are.True(strings.Contains(out, "version="))          // missing app version
+		are.True(strings.Contains(out, "trace.id="+traceID)) // missing trace ID
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are = is.New(t)
+			buf = new(bytes.Buffer)
+			tc  = logm.Trace{ID: traceID}
+			l   = logm.DefaultLogger(name, buf, logm.WithFormat(logm.JSONFormat))
+		)
+		l.Info(info, tc.LogAttr())
+		var out struct {
+			Msg     string  `json:"msg"`
+			App     string  `json:"app"`
+			Version *string `json:"version"`
+			Trace   struct {
+				ID string `json:"id"`
+			} `json:"trace"`
+		}
+		are.NoErr(json.Unmarshal(buf.Bytes(), &out)) // invalid JSON
+		are.Equal(info, out.Msg)                     // mismatch message
+		are.Equal(name, out.App)                     // mismatch app name
+		are.True(out.Version != nil)                 // missing app version
+		are.Equal(traceID, out.Trace.ID)             // mismatch trace ID
+	})
+}
@@ -0,0 +1,70 @@
+package logm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/exp/slog"
+)
+
+// Go runs fn in a new goroutine, recovering any panic to log it with the trace of the context.
+// On panic, the error is also given to each report function, like ReportTo.
+// See Recover for more details.
+func Go(ctx context.Context, l *slog.Logger, fn func(ctx context.Context), report ...func(error)) {
+	go func() {
+		defer Recover(ctx, l, report...)
+		fn(ctx)
+	}()
+}
+
+// Recover recovers a panic and logs it as ERROR with its stack trace, like RecoverHandler does.
+// The identifiers of the trace of the context, if any, are used to identify the panic.
+// On panic, the error is also given to each report function, like ReportTo.
+// It must be directly deferred to recover the panic:
+//
+//	defer logm.Recover(ctx, logger)
+func Recover(ctx context.Context, l *slog.Logger, report ...func(error)) {
+	pr := recover()
+	if pr == nil {
+		return
+	}
+	err := PanicError(pr)
+	if l != nil {
+		var attrs []slog.Attr
+		if t, ok := TraceFromContext(ctx); ok {
+			// Only the identifiers: the trace may be shared with and modified by another goroutine, see Go.
+			attrs = append(attrs, slog.Group(PanicKey, t.idAttrs()...))
+		}
+		attrs = append(attrs, slog.Any(StackKey, NewPanicStack(DefaultStackDepth)))
+		l.LogAttrs(ctx, slog.LevelError, err.Error(), attrs...)
+	}
+	for _, fn := range report {
+		if fn != nil {
+			fn(err)
+		}
+	}
+}
+
+// ReportTo returns a report function sending the errors to the channel ch, without blocking:
+// the error is dropped if the channel is not ready to receive it.
+func ReportTo(ch chan<- error) func(error) {
+	return func(err error) {
+		select {
+		case ch <- err:
+		default:
+		}
+	}
+}
+
+// PanicError returns the value recovered from a panic as an error.
+func PanicError(v any) error {
+	switch t := v.(type) {
+	case string:
+		return errors.New(t)
+	case error:
+		return t
+	default:
+		return fmt.Errorf("unsupported panic type: %#v", t)
+	}
+}
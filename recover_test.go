@@ -0,0 +1,94 @@
+package logm_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/rvflash/logm"
+)
+
+func TestGo(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		ch  = make(chan error, 1)
+		tr  = logm.NewTraceSpan(traceID)
+		ctx = tr.NewContext(context.Background())
+	)
+	tr.SetAttr(name, info)
+	logm.Go(ctx, logm.DefaultLogger(name, buf), func(ctx context.Context) {
+		panic(warn)
+	}, logm.ReportTo(ch))
+	// The parent goroutine keeps using its trace.
+	tr.SetAttr(name, debug)
+	tr.AddEvent(warn)
+	err := <-ch
+	are.Equal(warn, err.Error()) // mismatch error
+	out := buf.String()
+	are.True(!strings.Contains(out, "panic.attrs"))                                     // unexpected trace attributes
+	are.True(strings.Contains(out, "level=ERROR msg=earth"))                            // unexpected error message
+	are.True(strings.Contains(out, "panic.id="+traceID))                                // trace ID expected
+	are.True(strings.Contains(out, "stack.0.func=github.com/rvflash/logm_test.TestGo")) // unexpected stack trace
+}
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+	for desc, tc := range map[string]struct {
+		fn  func()
+		ctx context.Context
+		err error
+		out string
+	}{
+		"No panic": {
+			fn:  func() {},
+			ctx: context.Background(),
+		},
+		"Without trace": {
+			fn:  func() { panic(errors.New(warn)) },
+			ctx: context.Background(),
+			err: errors.New(warn),
+			out: "level=ERROR msg=earth",
+		},
+		"With trace": {
+			fn:  func() { panic(warn) },
+			ctx: logm.NewTraceSpan(traceID).NewContext(context.Background()),
+			err: errors.New(warn),
+			out: "panic.id=" + traceID,
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				are = is.New(t)
+				buf = new(bytes.Buffer)
+				err error
+			)
+			func() {
+				defer logm.Recover(tt.ctx, logm.DefaultLogger(name, buf), func(e error) {
+					err = e
+				})
+				tt.fn()
+			}()
+			are.Equal(tt.err, err)                           // mismatch error
+			are.True(strings.Contains(buf.String(), tt.out)) // unexpected log
+		})
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		err = errors.New(warn)
+	)
+	are.Equal(err, logm.PanicError(err))                                 // mismatch error
+	are.Equal(warn, logm.PanicError(warn).Error())                       // mismatch string
+	are.Equal("unsupported panic type: 42", logm.PanicError(42).Error()) // mismatch other type
+}
@@ -0,0 +1,238 @@
+package logm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// Masked is the value used by MaskStars to replace a sensitive value.
+const Masked = "***"
+
+// Mask masks a sensitive value. It returns false if the value must be dropped.
+type Mask func(v string) (string, bool)
+
+// MaskDrop drops the sensitive value.
+func MaskDrop(string) (string, bool) {
+	return "", false
+}
+
+// MaskHash replaces the sensitive value by its SHA-256 hash, hex-encoded.
+// It allows to correlate the values without revealing them.
+func MaskHash(v string) (string, bool) {
+	h := sha256.Sum256([]byte(v))
+	return "sha256:" + hex.EncodeToString(h[:]), true
+}
+
+// MaskKeepLast returns a Mask replacing the sensitive value by `***`, followed by its last n characters.
+// If the value has less than twice n characters, it's fully masked.
+func MaskKeepLast(n int) Mask {
+	return func(v string) (string, bool) {
+		r := []rune(v)
+		if n <= 0 || len(r) < 2*n {
+			return Masked, true
+		}
+		return Masked + string(r[len(r)-n:]), true
+	}
+}
+
+// MaskStars replaces the sensitive value by `***`.
+func MaskStars(string) (string, bool) {
+	return Masked, true
+}
+
+// RedactRule associates a key pattern to the mask to apply on its values.
+type RedactRule struct {
+	// Pattern is a case-insensitive shell pattern matching a key, like `*token*`. See path.Match.
+	// A malformed pattern only matches the identical key.
+	Pattern string
+	// Mask is the mask to apply. If nil, MaskStars is used.
+	Mask Mask
+}
+
+// DefaultRedactRules returns the rules masking with `***` the usual sensitive keys,
+// like passwords, secrets, tokens, API keys, authorization or cookie headers.
+func DefaultRedactRules() []RedactRule {
+	return []RedactRule{
+		{Pattern: "*password*"},
+		{Pattern: "*passwd*"},
+		{Pattern: "*secret*"},
+		{Pattern: "*token*"},
+		{Pattern: "*api?key*"},
+		{Pattern: "*apikey*"},
+		{Pattern: "authorization"},
+		{Pattern: "proxy-authorization"},
+		{Pattern: "cookie"},
+		{Pattern: "set-cookie"},
+	}
+}
+
+// NewRedactor returns a Redactor applying these rules. The first matching rule wins.
+func NewRedactor(rules ...RedactRule) *Redactor {
+	r := &Redactor{rules: make([]RedactRule, len(rules))}
+	for k, v := range rules {
+		v.Pattern = strings.ToLower(v.Pattern)
+		if v.Mask == nil {
+			v.Mask = MaskStars
+		}
+		r.rules[k] = v
+	}
+	return r
+}
+
+// Redactor masks the sensitive values of query parameters, HTTP headers and slog attributes, based on their key.
+// A nil Redactor masks nothing.
+type Redactor struct {
+	rules []RedactRule
+}
+
+// Header returns a copy of the HTTP header where the sensitive values are masked.
+func (r *Redactor) Header(h http.Header) http.Header {
+	res := make(http.Header, len(h))
+	for k, a := range h {
+		var (
+			m  Mask
+			ok bool
+		)
+		if m, ok = r.match(k); !ok {
+			res[k] = append([]string(nil), a...)
+			continue
+		}
+		for _, v := range a {
+			if v, ok = m(v); ok {
+				res[k] = append(res[k], v)
+			}
+		}
+	}
+	return res
+}
+
+// Query returns the raw query where the sensitive values are masked.
+// The order and the encoding of the other parameters are preserved.
+func (r *Redactor) Query(raw string) string {
+	if r == nil || raw == "" {
+		return raw
+	}
+	var (
+		p   = strings.Split(raw, "&")
+		res = p[:0]
+	)
+	for _, s := range p {
+		k, v, _ := strings.Cut(s, "=")
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			key = k
+		}
+		m, ok := r.match(key)
+		if !ok {
+			res = append(res, s)
+			continue
+		}
+		val, err := url.QueryUnescape(v)
+		if err != nil {
+			val = v
+		}
+		if val, ok = m(val); ok {
+			res = append(res, k+"="+url.QueryEscape(val))
+		}
+	}
+	return strings.Join(res, "&")
+}
+
+// Body returns the body of this content type where the sensitive values are masked.
+// The form-urlencoded bodies are masked like a query, see Query. The JSON bodies are masked by key,
+// whatever their depth, then re-encoded with sorted keys. It returns false if a JSON body can not be parsed,
+// like a truncated one, as its sensitive values can not be masked. The other bodies are returned as is.
+func (r *Redactor) Body(contentType string, body []byte) ([]byte, bool) {
+	if r == nil || len(r.rules) == 0 || len(body) == 0 {
+		return body, true
+	}
+	t, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case t == "application/x-www-form-urlencoded":
+		return []byte(r.Query(string(body))), true
+	case t == "application/json", strings.HasSuffix(t, "+json"):
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		var v any
+		if err := d.Decode(&v); err != nil {
+			return nil, false
+		}
+		buf := new(bytes.Buffer)
+		e := json.NewEncoder(buf)
+		e.SetEscapeHTML(false)
+		if err := e.Encode(r.json(v)); err != nil {
+			return nil, false
+		}
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+	}
+	return body, true
+}
+
+// json masks the sensitive values of the decoded JSON value v.
+func (r *Redactor) json(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, v := range t {
+			m, ok := r.match(k)
+			if !ok {
+				t[k] = r.json(v)
+				continue
+			}
+			var s string
+			switch vv := v.(type) {
+			case string:
+				s = vv
+			default:
+				b, _ := json.Marshal(vv)
+				s = string(b)
+			}
+			if s, ok = m(s); ok {
+				t[k] = s
+			} else {
+				delete(t, k)
+			}
+		}
+	case []any:
+		for k, v := range t {
+			t[k] = r.json(v)
+		}
+	}
+	return v
+}
+
+// ReplaceAttr masks the value of the sensitive attributes, whatever their group.
+// Its signature allows to use it as slog.HandlerOptions.ReplaceAttr, see also WithRedactor.
+func (r *Redactor) ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	m, ok := r.match(a.Key)
+	if !ok {
+		return a
+	}
+	v, ok := m(a.Value.Resolve().String())
+	if !ok {
+		return slog.Attr{}
+	}
+	return slog.String(a.Key, v)
+}
+
+func (r *Redactor) match(key string) (Mask, bool) {
+	if r == nil {
+		return nil, false
+	}
+	key = strings.ToLower(key)
+	for _, rule := range r.rules {
+		ok, err := path.Match(rule.Pattern, key)
+		if ok || (err != nil && rule.Pattern == key) {
+			return rule.Mask, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,178 @@
+package logm_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/matryer/is"
+	"github.com/rvflash/logm"
+
+	"golang.org/x/exp/slog"
+)
+
+const secret = "s3cr3t-t0k3n"
+
+func TestMask(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		mask logm.Mask
+		in   string
+		out  string
+		ok   bool
+	}{
+		"Drop":              {mask: logm.MaskDrop, in: secret},
+		"Stars":             {mask: logm.MaskStars, in: secret, out: logm.Masked, ok: true},
+		"Hash":              {mask: logm.MaskHash, in: "a", out: "sha256:ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb", ok: true},
+		"Keep last":         {mask: logm.MaskKeepLast(4), in: secret, out: "***0k3n", ok: true},
+		"Keep last (short)": {mask: logm.MaskKeepLast(4), in: "1234567", out: logm.Masked, ok: true},
+		"Keep none":         {mask: logm.MaskKeepLast(0), in: secret, out: logm.Masked, ok: true},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			out, ok := tt.mask(tt.in)
+			are.Equal(tt.ok, ok)   // mismatch keep
+			are.Equal(tt.out, out) // mismatch value
+		})
+	}
+}
+
+func TestRedactor_Query(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		r   *logm.Redactor
+		in  string
+		out string
+	}{
+		"Default":      {in: "token=" + secret, out: "token=" + secret},
+		"Blank":        {r: logm.NewRedactor(logm.DefaultRedactRules()...)},
+		"No sensitive": {r: logm.NewRedactor(logm.DefaultRedactRules()...), in: "q=1&q=2&p", out: "q=1&q=2&p"},
+		"Stars": {
+			r:   logm.NewRedactor(logm.DefaultRedactRules()...),
+			in:  "q=1&access_token=" + secret + "&Password=oops&p",
+			out: "q=1&access_token=%2A%2A%2A&Password=%2A%2A%2A&p",
+		},
+		"Drop": {
+			r:   logm.NewRedactor(logm.RedactRule{Pattern: "email", Mask: logm.MaskDrop}),
+			in:  "email=me%40example.com&q=1",
+			out: "q=1",
+		},
+		"Keep last": {
+			r:   logm.NewRedactor(logm.RedactRule{Pattern: "card", Mask: logm.MaskKeepLast(4)}),
+			in:  "card=4242424242424242",
+			out: "card=%2A%2A%2A4242",
+		},
+		"Malformed pattern": {
+			r:   logm.NewRedactor(logm.RedactRule{Pattern: "[email"}),
+			in:  "[email=me&email=me",
+			out: "[email=%2A%2A%2A&email=me",
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			are.Equal(tt.out, tt.r.Query(tt.in)) // mismatch query
+		})
+	}
+}
+
+func TestRedactor_Body(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+	r := logm.NewRedactor(logm.DefaultRedactRules()...)
+
+	for desc, tc := range map[string]struct {
+		r     *logm.Redactor
+		ctype string
+		in    string
+		out   string
+		ok    bool
+	}{
+		"Default": {ctype: "application/json", in: `{"token":"` + secret + `"}`, out: `{"token":"` + secret + `"}`, ok: true},
+		"Form": {
+			r:     r,
+			ctype: "application/x-www-form-urlencoded",
+			in:    "login=me&password=" + secret,
+			out:   "login=me&password=%2A%2A%2A",
+			ok:    true,
+		},
+		"JSON": {
+			r:     r,
+			ctype: "application/json; charset=utf-8",
+			in:    `{"user":{"login":"<me>","token":"` + secret + `"},"items":[{"password":42}],"n":1.50}`,
+			out:   `{"items":[{"password":"***"}],"n":1.50,"user":{"login":"<me>","token":"***"}}`,
+			ok:    true,
+		},
+		"JSON problem": {
+			r:     r,
+			ctype: "application/problem+json",
+			in:    `{"secret":{"a":1}}`,
+			out:   `{"secret":"***"}`,
+			ok:    true,
+		},
+		"Truncated JSON": {r: r, ctype: "application/json", in: `{"token":"` + secret[:3]},
+		"Text":           {r: r, ctype: "text/plain", in: "token=" + secret, out: "token=" + secret, ok: true},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			out, ok := tt.r.Body(tt.ctype, []byte(tt.in))
+			are.Equal(tt.ok, ok)           // mismatch result
+			are.Equal(tt.out, string(out)) // mismatch body
+		})
+	}
+}
+
+func TestRedactor_Header(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		r   = logm.NewRedactor(
+			logm.RedactRule{Pattern: "cookie", Mask: logm.MaskDrop},
+			logm.RedactRule{Pattern: "authorization"},
+		)
+		in = http.Header{
+			"Authorization": {"Bearer " + secret},
+			"Cookie":        {"id=" + secret},
+			"User-Agent":    {"test"},
+		}
+		out = r.Header(in)
+	)
+	are.Equal("", cmp.Diff(http.Header{
+		"Authorization": {logm.Masked},
+		"User-Agent":    {"test"},
+	}, out)) // mismatch header
+	are.Equal("Bearer "+secret, in.Get("Authorization")) // unexpected modification
+}
+
+func TestRedactor_ReplaceAttr(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		log = logm.New(name,
+			logm.WithWriter(buf),
+			logm.WithRedactor(logm.NewRedactor(append(
+				logm.DefaultRedactRules(),
+				logm.RedactRule{Pattern: "email", Mask: logm.MaskDrop},
+			)...)),
+		)
+	)
+	log.Info(info, "password", secret, slog.Group("user", slog.String("email", "me@example.com"), slog.Int("id", 1)))
+	out := buf.String()
+	are.True(!strings.Contains(out, secret))        // unexpected secret
+	are.True(strings.Contains(out, "password=***")) // expected masked password
+	are.True(!strings.Contains(out, "email"))       // unexpected email
+	are.True(strings.Contains(out, "user.id=1"))    // expected user ID
+	are.True(strings.Contains(out, "app="+name))    // expected app name
+}
@@ -0,0 +1,161 @@
+package logm
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// SamplingSummaryMessage is the message of the record summarizing the records dropped by sampling.
+const SamplingSummaryMessage = "sampling: records dropped"
+
+// NewSamplingHandler returns a slog.Handler wrapping h to sample the records identified by their level and message.
+// During each interval, the first records are all handled, then only 1 in thereafter is handled.
+// If thereafter is zero, all the next records of the interval are dropped.
+// At the end of the interval, a WARN record by level and message summarizes the number of dropped records.
+func NewSamplingHandler(h slog.Handler, first, thereafter int, interval time.Duration) slog.Handler {
+	return &samplingHandler{
+		h: h,
+		s: &sampler{
+			root:       h,
+			counts:     make(map[samplingKey]int),
+			first:      first,
+			thereafter: thereafter,
+			interval:   interval,
+		},
+	}
+}
+
+type samplingHandler struct {
+	h slog.Handler
+	s *sampler
+}
+
+// Enabled implements the slog.Handler interface.
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+// Handle implements the slog.Handler interface.
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.s.sample(r) {
+		return nil
+	}
+	return h.h.Handle(ctx, r)
+}
+
+// WithAttrs implements the slog.Handler interface.
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{h: h.h.WithAttrs(attrs), s: h.s}
+}
+
+// WithGroup implements the slog.Handler interface.
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{h: h.h.WithGroup(name), s: h.s}
+}
+
+type samplingKey struct {
+	msg   string
+	level slog.Level
+}
+
+// sampler is shared by all the handlers derived from the same sampling handler.
+type sampler struct {
+	root       slog.Handler
+	start      time.Time
+	counts     map[samplingKey]int
+	dropped    map[samplingKey]int64
+	timer      *time.Timer
+	first      int
+	thereafter int
+	interval   time.Duration
+	mu         sync.Mutex
+}
+
+// sample reports whether the record must be handled.
+func (s *sampler) sample(r slog.Record) bool {
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	s.mu.Lock()
+	var dropped map[samplingKey]int64
+	if now.Sub(s.start) >= s.interval {
+		dropped = s.reset()
+		s.start = now
+		s.counts = make(map[samplingKey]int, len(s.counts))
+	}
+	k := samplingKey{msg: r.Message, level: r.Level}
+	s.counts[k]++
+	n := s.counts[k] - s.first
+	ok := n <= 0 || (s.thereafter > 0 && n%s.thereafter == 0)
+	if !ok {
+		if s.dropped == nil {
+			s.dropped = make(map[samplingKey]int64)
+		}
+		s.dropped[k]++
+		if s.timer == nil {
+			// Summarizes the dropped records at the end of the interval, even without any further record.
+			var t *time.Timer
+			t = time.AfterFunc(s.interval-now.Sub(s.start), func() {
+				s.mu.Lock()
+				if s.timer != t {
+					// Already summarized by a record of the next interval.
+					s.mu.Unlock()
+					return
+				}
+				dropped := s.reset()
+				s.mu.Unlock()
+				s.summarize(time.Now(), dropped)
+			})
+			s.timer = t
+		}
+	}
+	s.mu.Unlock()
+
+	s.summarize(now, dropped)
+	return ok
+}
+
+// reset returns the records dropped since the last summary and cancels its pending timer.
+// It must be called with the lock held.
+func (s *sampler) reset() map[samplingKey]int64 {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	dropped := s.dropped
+	s.dropped = nil
+	return dropped
+}
+
+// summarize logs a WARN record with the number of dropped records by level and message.
+// The context of the current record is not used: its trace and attributes are not related to the dropped records.
+func (s *sampler) summarize(now time.Time, dropped map[samplingKey]int64) {
+	ctx := context.Background()
+	if len(dropped) == 0 || !s.root.Enabled(ctx, slog.LevelWarn) {
+		return
+	}
+	keys := make([]samplingKey, 0, len(dropped))
+	for k := range dropped {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].level != keys[j].level {
+			return keys[i].level < keys[j].level
+		}
+		return keys[i].msg < keys[j].msg
+	})
+	for _, k := range keys {
+		r := slog.NewRecord(now, slog.LevelWarn, SamplingSummaryMessage, 0)
+		r.AddAttrs(
+			slog.Any(SamplingLevelKey, k.level),
+			slog.String(SamplingMessageKey, k.msg),
+			slog.Int64(SamplingDroppedKey, dropped[k]),
+		)
+		_ = s.root.Handle(ctx, r)
+	}
+}
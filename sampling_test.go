@@ -0,0 +1,106 @@
+package logm_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/rvflash/logm"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestNewSamplingHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are = is.New(t)
+			buf = new(bytes.Buffer)
+			log = logm.New(name, logm.WithWriter(buf), logm.WithSampling(2, 3, time.Hour))
+		)
+		for i := 0; i < 10; i++ {
+			log.Info(info, "i", i)
+		}
+		log.Warn(info)
+		log.With("key", "value").Info(warn)
+
+		out := buf.String()
+		are.Equal(4, strings.Count(out, "level=INFO msg="+info))      // mismatch sampled records
+		are.True(strings.Contains(out, "i=0"))                        // missing first record
+		are.True(strings.Contains(out, "i=1"))                        // missing second record
+		are.True(strings.Contains(out, "i=4"))                        // missing 1 in 3 record
+		are.True(strings.Contains(out, "i=7"))                        // missing 1 in 3 record
+		are.True(strings.Contains(out, "level=WARN msg="+info))       // missing record with another level
+		are.True(strings.Contains(out, "msg="+warn))                  // missing record with another message
+		are.True(!strings.Contains(out, logm.SamplingSummaryMessage)) // unexpected summary
+	})
+
+	t.Run("Summary", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are = is.New(t)
+			out = make(lineWriter, 10)
+			hdl = slog.HandlerOptions{}.NewTextHandler(out)
+			log = slog.New(logm.NewSamplingHandler(hdl, 1, 0, 50*time.Millisecond))
+		)
+		for i := 0; i < 5; i++ {
+			log.WithGroup("g").Info(info)
+		}
+		for i := 0; i < 3; i++ {
+			log.Warn(warn)
+		}
+		are.True(strings.Contains(<-out, "msg="+info)) // missing first record
+		are.True(strings.Contains(<-out, "msg="+warn)) // missing first record with another message
+		// Without any further record, the summaries are logged at the end of the interval.
+		for _, s := range []string{
+			`sampled_level=INFO sampled_msg=` + info + ` dropped=4`,
+			`sampled_level=WARN sampled_msg=` + warn + ` dropped=2`,
+		} {
+			select {
+			case line := <-out:
+				are.True(strings.Contains(line, `level=WARN msg="`+logm.SamplingSummaryMessage+`"`)) // missing summary
+				are.True(strings.Contains(line, s))                                                  // mismatch dropped records
+			case <-time.After(time.Second):
+				t.Fatal("missing summary")
+			}
+		}
+	})
+}
+
+// lineWriter sends each written line.
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestNewSamplingHandler_context(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		buf = new(bytes.Buffer)
+		hdl = logm.NewSamplingHandler(logm.NewContextHandler(slog.HandlerOptions{}.NewTextHandler(buf)), 1, 0, time.Hour)
+		now = time.Now()
+		ctx = logm.NewTraceSpan(traceID).NewContext(logm.ContextWithAttrs(context.Background(), slog.String(name, debug)))
+	)
+	for i := 0; i < 3; i++ {
+		are.NoErr(hdl.Handle(context.Background(), slog.NewRecord(now, slog.LevelInfo, info, 0))) // unexpected error
+	}
+	// The next interval starts with a record with its own context.
+	are.NoErr(hdl.Handle(ctx, slog.NewRecord(now.Add(time.Hour), slog.LevelInfo, info, 0))) // unexpected error
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	are.Equal(3, len(lines))                                          // mismatch records
+	are.True(strings.Contains(lines[1], logm.SamplingSummaryMessage)) // missing summary
+	are.True(strings.Contains(lines[1], "dropped=2"))                 // mismatch dropped records
+	are.True(!strings.Contains(lines[1], "trace.id="))                // unexpected trace of the next record
+	are.True(!strings.Contains(lines[1], name+"="+debug))             // unexpected attribute of the next record
+	are.True(strings.Contains(lines[2], "trace.id="+traceID))         // missing trace of the next record
+	are.True(strings.Contains(lines[2], name+"="+debug))              // missing attribute of the next record
+}
@@ -0,0 +1,137 @@
+package logm
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// DefaultStackDepth is the default maximum number of frames of a stack trace.
+const DefaultStackDepth = 32
+
+// pkgPath is the import path of this package.
+var pkgPath = reflect.TypeOf(StackFrame{}).PkgPath()
+
+// wrapperFrames lists the functions only wrapping the calls of the user code, trimmed of the stack traces,
+// with their closures: the middlewares of this package and the http.HandlerFunc adapter used to chain them.
+// The other frames of this package are kept to locate a panic raised in it. See RegisterWrapperFrames.
+var wrapperFrames = struct {
+	m  map[string]struct{}
+	mu sync.RWMutex
+}{
+	m: map[string]struct{}{
+		"net/http.HandlerFunc.ServeHTTP":       {},
+		pkgPath + ".Go":                        {},
+		pkgPath + ".Middleware.LogHandler":     {},
+		pkgPath + ".Middleware.RecoverHandler": {},
+		pkgPath + ".Middleware.TraceHandler":   {},
+	},
+}
+
+// RegisterWrapperFrames registers the fully qualified names of functions only wrapping the calls of the user code,
+// like middlewares or interceptors, to trim them and their closures of the stack traces, see NewPanicStack.
+// A name is formatted as in runtime.Frame, like "github.com/rvflash/logm.Middleware.LogHandler".
+// It's designed to be called in an init function.
+func RegisterWrapperFrames(functions ...string) {
+	wrapperFrames.mu.Lock()
+	defer wrapperFrames.mu.Unlock()
+	for _, f := range functions {
+		wrapperFrames.m[f] = struct{}{}
+	}
+}
+
+// NewPanicStack returns the stack trace of the current goroutine, limited to depth frames.
+// Designed to be called in a deferred function recovering a panic, the stack starts from the panicking call.
+// The frames of the runtime and the known wrapper frames, like the HTTP middlewares of this package, are trimmed.
+// If depth is zero or negative, DefaultStackDepth is used.
+func NewPanicStack(depth int) Stack {
+	if depth <= 0 {
+		depth = DefaultStackDepth
+	}
+	var (
+		pc     = make([]uintptr, depth+DefaultStackDepth)
+		frames = runtime.CallersFrames(pc[:runtime.Callers(2, pc)])
+		res    = make(Stack, 0, depth)
+	)
+	for {
+		f, more := frames.Next()
+		switch {
+		case f.Function == "runtime.gopanic":
+			// Only keeps the frames after the panic.
+			res = res[:0]
+		case strings.HasPrefix(f.Function, "runtime."), isWrapperFrame(f.Function):
+		default:
+			res = append(res, StackFrame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	if len(res) > depth {
+		res = res[:depth]
+	}
+	return res
+}
+
+// isWrapperFrame reports whether the function, or the one enclosing it if it's a closure, is a wrapper frame.
+// Depending on the compiler, a closure is named by its enclosing function suffixed by ".funcN" or ".N".
+func isWrapperFrame(function string) bool {
+	wrapperFrames.mu.RLock()
+	defer wrapperFrames.mu.RUnlock()
+	for {
+		if _, ok := wrapperFrames.m[function]; ok {
+			return true
+		}
+		i := strings.LastIndexByte(function, '.')
+		if i < 0 || !isClosureName(function[i+1:]) {
+			return false
+		}
+		function = function[:i]
+	}
+}
+
+func isClosureName(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// StackFrame is a frame of a stack trace.
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (f StackFrame) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String(StackFunctionKey, f.Function),
+		slog.String(StackFileKey, f.File),
+		slog.Int(StackLineKey, f.Line),
+	)
+}
+
+// Stack is a stack trace, starting from the most recent call.
+type Stack []StackFrame
+
+// LogValue implements the slog.LogValuer interface.
+// Each frame is a group, named by its index in the stack.
+func (s Stack) LogValue() slog.Value {
+	attrs := make([]slog.Attr, len(s))
+	for k, f := range s {
+		attrs[k] = slog.Any(strconv.Itoa(k), f)
+	}
+	return slog.GroupValue(attrs...)
+}
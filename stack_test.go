@@ -0,0 +1,127 @@
+package logm_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/rvflash/logm"
+)
+
+func TestNewPanicStack(t *testing.T) {
+	t.Parallel()
+	for desc, tc := range map[string]struct {
+		depth int
+		size  int
+	}{
+		"Default": {size: 3},
+		"Limited": {depth: 1, size: 1},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				are = is.New(t)
+				res logm.Stack
+			)
+			func() {
+				defer func() {
+					_ = recover()
+					res = logm.NewPanicStack(tt.depth)
+				}()
+				panic(warn)
+			}()
+			are.Equal(tt.size, len(res))                                                  // mismatch depth
+			are.True(strings.HasPrefix(res[0].Function, "github.com/rvflash/logm_test.")) // panicking function expected first
+			are.True(strings.HasSuffix(res[0].File, "stack_test.go"))                     // mismatch file
+			are.True(res[0].Line > 0)                                                     // missing line
+		})
+	}
+}
+
+func TestNewPanicStack_frames(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		fn    func()
+		first string
+	}{
+		"Middleware": {
+			fn: func() {
+				var (
+					m    = logm.Middleware{Logger: logm.DefaultLogger(name, io.Discard)}
+					next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(warn) })
+				)
+				m.LogHandler(m.TraceHandler(next)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+			},
+			first: "github.com/rvflash/logm_test.TestNewPanicStack_frames.func1.1",
+		},
+		"Package": {
+			fn: func() {
+				var w *logm.AsyncWriter
+				_ = w.DroppedBytes()
+			},
+			first: "github.com/rvflash/logm.(*AsyncWriter).DroppedBytes",
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var res logm.Stack
+			func() {
+				defer func() {
+					_ = recover()
+					res = logm.NewPanicStack(0)
+				}()
+				tt.fn()
+			}()
+			are.True(len(res) > 0)               // missing frames
+			are.Equal(tt.first, res[0].Function) // mismatch panicking function
+			for _, f := range res {
+				are.True(f.Function != "net/http.HandlerFunc.ServeHTTP")                       // unexpected adapter frame
+				are.True(!strings.HasPrefix(f.Function, "github.com/rvflash/logm.Middleware")) // unexpected middleware frame
+			}
+		})
+	}
+}
+
+// wrapper only wraps the call of fn.
+func wrapper(fn func()) {
+	fn()
+}
+
+func TestRegisterWrapperFrames(t *testing.T) {
+	t.Parallel()
+	const function = "github.com/rvflash/logm_test.wrapper"
+
+	var (
+		are   = is.New(t)
+		stack = func() (res logm.Stack) {
+			defer func() {
+				_ = recover()
+				res = logm.NewPanicStack(0)
+			}()
+			wrapper(func() {
+				panic(warn)
+			})
+			return
+		}
+		trimmed = func(s logm.Stack) bool {
+			for _, f := range s {
+				if f.Function == function {
+					return false
+				}
+			}
+			return true
+		}
+	)
+	are.True(!trimmed(stack())) // expected wrapper frame
+	logm.RegisterWrapperFrames(function)
+	are.True(trimmed(stack())) // unexpected wrapper frame
+}
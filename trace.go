@@ -2,77 +2,377 @@ package logm
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
-
 	"golang.org/x/exp/slog"
 )
 
-// TraceIDHTTPHeader is the name of the HTTP header used to share a trace context ID.
-const TraceIDHTTPHeader = "X-Trace-Id"
+// List of HTTP headers used to propagate a trace context.
+const (
+	// TraceIDHTTPHeader is the name of the HTTP header used to share a trace context ID.
+	TraceIDHTTPHeader = "X-Trace-Id"
+	// TraceParentHTTPHeader is the name of the W3C Trace Context header sharing the trace and parent identifiers.
+	TraceParentHTTPHeader = "Traceparent"
+	// TraceStateHTTPHeader is the name of the W3C Trace Context header sharing vendor-specific trace data.
+	TraceStateHTTPHeader = "Tracestate"
+)
+
+// ErrInvalidTraceParent is returned when a W3C traceparent value can not be parsed.
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
 
-// NewTrace creates a new Trace with a new generated UUID v4 as identifier.
+const (
+	traceParentVersion = "00"
+	traceIDHexLen      = 32
+	spanIDHexLen       = 16
+	flagsHexLen        = 2
+	// See https://www.w3.org/TR/trace-context/#tracestate-limits
+	traceStateMaxMembers   = 32
+	traceStateMaxLen       = 512
+	traceStateMaxMemberLen = 128
+)
+
+// NewTrace creates a new Trace with a new identifier, generated by the IDGenerator, UUID v4 by default.
+// See SetIDGenerator to change it.
 func NewTrace() *Trace {
-	return &Trace{ID: newUUID()}
+	return newRootTrace(nil)
+}
+
+// NewTraceWithIDGenerator creates a new Trace with a new identifier generated by g.
+// If g is nil, the default IDGenerator is used, like NewTrace.
+func NewTraceWithIDGenerator(g IDGenerator) *Trace {
+	return newRootTrace(g)
+}
+
+// newRootTrace creates the root span of a new trace, identified by g or the default IDGenerator if nil.
+func newRootTrace(g IDGenerator) *Trace {
+	return &Trace{ID: newTraceID(g), SpanID: newSpanID()}
 }
 
 type contextual string
 
-const ctxTraceID contextual = "traceID"
+const (
+	ctxAttrs contextual = "attrs"
+	ctxTrace contextual = "trace"
+)
 
 // NewTraceFromContext returns a new Trace based on the context.Context.
-// If the trace ID value is not found or blank, a new one is created.
-// Otherwise, we create a trace span with this trace identifier as parent identifier.
+// If the trace is not found or its ID is blank, a new one is created.
+// Otherwise, we create a child span of the current span of the context.
 func NewTraceFromContext(ctx context.Context) *Trace {
-	return NewTraceSpan(contextValue(ctx, ctxTraceID))
+	return newTraceFromContext(ctx, nil)
 }
 
-func contextValue(ctx context.Context, key contextual) string {
-	if v, ok := ctx.Value(key).(string); ok {
-		return v
+func newTraceFromContext(ctx context.Context, g IDGenerator) *Trace {
+	if t, ok := TraceFromContext(ctx); ok {
+		return t.NewSpan()
 	}
-	return ""
+	return newRootTrace(g)
+}
+
+// TraceFromContext returns the current span of the context.Context, if any.
+// Unlike NewTraceFromContext, it never creates a new identifier.
+func TraceFromContext(ctx context.Context) (*Trace, bool) {
+	t, ok := ctx.Value(ctxTrace).(*Trace)
+	if !ok || t == nil || t.ID == "" {
+		return nil, false
+	}
+	return t, true
 }
 
 // NewTraceFromHTTPRequest returns a new Trace based on the http.Request.
+// The W3C traceparent and tracestate headers are used first, then the X-Trace-Id header as fallback.
 // If the trace ID value is not found or blank, a new one is created.
 // Otherwise, we create a trace span with this trace identifier as parent identifier.
 func NewTraceFromHTTPRequest(req *http.Request) *Trace {
-	return NewTraceSpan(req.Header.Get(TraceIDHTTPHeader))
+	return newTraceFromHTTPRequest(req, nil)
+}
+
+func newTraceFromHTTPRequest(req *http.Request, g IDGenerator) *Trace {
+	if t, ok := TraceFromHTTPRequest(req); ok {
+		return t.NewSpan()
+	}
+	return newRootTrace(g)
+}
+
+// TraceFromHTTPRequest returns the remote span shared by the http.Request headers, if any.
+// The W3C traceparent and tracestate headers are used first, then the X-Trace-Id header as fallback.
+// With traceparent, the SpanID is the one of the caller, without, the span is unknown.
+// An X-Trace-Id value is ignored if it's not a valid trace ID, see ValidTraceID.
+// The tracestate is dropped if malformed and limited to 512 characters, as defined by W3C Trace Context.
+// If it matches the traceparent trace ID, like an UUID with dashes, its form is kept as trace ID,
+// to log the same identifier on both sides.
+// Unlike NewTraceFromHTTPRequest, it never creates a new identifier.
+func TraceFromHTTPRequest(req *http.Request) (*Trace, bool) {
+	t, err := ParseTraceParent(req.Header.Get(TraceParentHTTPHeader))
+	if err == nil {
+		t.State = parseTraceState(req.Header.Values(TraceStateHTTPHeader))
+		if id, ok := normalizeTraceID(req.Header.Get(TraceIDHTTPHeader)); ok && traceParentID(id) == t.ID {
+			t.ID = id
+		}
+		return t, true
+	}
+	if id, ok := normalizeTraceID(req.Header.Get(TraceIDHTTPHeader)); ok {
+		return &Trace{ID: id}, true
+	}
+	return nil, false
+}
+
+// ParseTraceParent parses a W3C traceparent value, formatted as `version-trace_id-parent_id-flags`.
+// The returned Trace describes the remote span: its SpanID is the parent identifier.
+// Future versions are accepted as long as they start with the fields known by the version 00.
+func ParseTraceParent(s string) (*Trace, error) {
+	p := strings.Split(strings.TrimSpace(s), "-")
+	if len(p) < 4 {
+		return nil, fmt.Errorf("%q: %w", s, ErrInvalidTraceParent)
+	}
+	version, traceID, spanID, flags := p[0], p[1], p[2], p[3]
+	switch {
+	case !isHex(version, flagsHexLen) || version == "ff",
+		version == traceParentVersion && len(p) != 4,
+		!isHex(traceID, traceIDHexLen) || isZero(traceID),
+		!isHex(spanID, spanIDHexLen) || isZero(spanID),
+		!isHex(flags, flagsHexLen):
+		return nil, fmt.Errorf("%q: %w", s, ErrInvalidTraceParent)
+	}
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", s, ErrInvalidTraceParent)
+	}
+	return &Trace{
+		ID:     traceID,
+		SpanID: spanID,
+		Flags:  byte(f),
+	}, nil
+}
+
+// isHex reports whether s is a lowercase hexadecimal string of length n.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// parseTraceState returns the W3C tracestate of these header values, limited to 512 characters,
+// by removing the members larger than 128 characters first, then the last ones.
+// A malformed tracestate, with an invalid or duplicated member, or more than 32 members, is dropped.
+func parseTraceState(values []string) string {
+	var (
+		members []string
+		keys    = make(map[string]struct{})
+	)
+	for _, v := range values {
+		for _, m := range strings.Split(v, ",") {
+			m = strings.Trim(m, " \t")
+			if m == "" {
+				// Empty members are allowed.
+				continue
+			}
+			key, value, ok := strings.Cut(m, "=")
+			if !ok || !isTraceStateKey(key) || !isTraceStateValue(value) {
+				return ""
+			}
+			if _, ok = keys[key]; ok {
+				return ""
+			}
+			keys[key] = struct{}{}
+			members = append(members, m)
+		}
+	}
+	if len(members) > traceStateMaxMembers {
+		return ""
+	}
+	size := len(members) - 1
+	for _, m := range members {
+		size += len(m)
+	}
+	for i := len(members) - 1; i >= 0 && size > traceStateMaxLen; i-- {
+		if len(members[i]) > traceStateMaxMemberLen {
+			size -= len(members[i]) + 1
+			members = append(members[:i], members[i+1:]...)
+		}
+	}
+	for size > traceStateMaxLen {
+		size -= len(members[len(members)-1]) + 1
+		members = members[:len(members)-1]
+	}
+	return strings.Join(members, ",")
+}
+
+// isTraceStateKey reports whether s is a tracestate key: `tenant@system` for multi-tenant vendors,
+// a simple key otherwise.
+func isTraceStateKey(s string) bool {
+	tenant, system, ok := strings.Cut(s, "@")
+	if !ok {
+		return isTraceStateKeyPart(s, 256, false)
+	}
+	return isTraceStateKeyPart(tenant, 241, true) && isTraceStateKeyPart(system, 14, false)
+}
+
+// isTraceStateKeyPart reports whether s has up to n characters among lowercase letters, digits, `_`, `-`, `*`
+// and `/`, starting by a lowercase letter, or a digit if allowed.
+func isTraceStateKeyPart(s string, n int, digit bool) bool {
+	if s == "" || len(s) > n {
+		return false
+	}
+	for k, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9':
+			if k == 0 && !digit {
+				return false
+			}
+		case k > 0 && (c == '_' || c == '-' || c == '*' || c == '/'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isTraceStateValue reports whether s is a tracestate value: up to 256 printable ASCII characters,
+// except `,` and `=`, not ending with a space.
+func isTraceStateValue(s string) bool {
+	if s == "" || len(s) > 256 || s[len(s)-1] == ' ' {
+		return false
+	}
+	for _, c := range s {
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
 }
 
-// NewTraceSpan creates a trace span based on the Trace.
+// NewTraceSpan creates a trace span of the trace identified by parentID.
+// As the parent span is unknown, the span has no parent span ID. See Trace.NewSpan to create a child span.
 func NewTraceSpan(parentID string) *Trace {
 	if parentID == "" {
 		return NewTrace()
 	}
 	return &Trace{
 		ID:     parentID,
-		SpanID: newUUID(),
+		SpanID: newSpanID(),
 	}
 }
 
-func newUUID() string {
-	id, err := uuid.NewRandom()
-	if err != nil {
-		return ""
+// newSpanID returns a random 8-byte identifier, hex-encoded as expected by the W3C Trace Context.
+func newSpanID() string {
+	b := make([]byte, spanIDHexLen/2)
+	if _, err := rand.Read(b); err != nil {
+		binary.BigEndian.PutUint64(b, mrand.Uint64()|1) //nolint:gosec // fallback, never all zeros
 	}
-	return id.String()
+	return hex.EncodeToString(b)
 }
 
+// List of span statuses, see Trace.SetStatus.
+const (
+	TraceStatusOK    = "ok"
+	TraceStatusError = "error"
+)
+
+// DurationUnit is the unit of the trace duration in structured log, by default the millisecond.
+// Supported units are time.Nanosecond, time.Microsecond, time.Millisecond and time.Second,
+// any other is considered as time.Second. It's designed to be defined at startup.
+var DurationUnit = time.Millisecond
+
 // Trace represents a trace context.
+// TimeElapsedMs is kept for backward compatibility, Duration is the full precision time elapsed.
 type Trace struct {
 	TimeElapsedMs int64
+	Duration      time.Duration
 	StartTime     time.Time
 	ID            string
 	SpanID        string
+	ParentSpanID  string
+	// State is the W3C tracestate, propagated as is once validated and limited, see TraceFromHTTPRequest.
+	State string
+	// Flags is the W3C trace flags, like the sampled one.
+	Flags byte
+
+	// Attrs are the span attributes, see SetAttr.
+	Attrs []slog.Attr
+	// Events are the span events, see AddEvent.
+	Events []TraceEvent
+	// Status is the span status, TraceStatusOK or TraceStatusError, and Err its error. See SetStatus.
+	Status string
+	Err    error
+}
+
+// TraceEvent is a timestamped event of a span.
+type TraceEvent struct {
+	Time  time.Time
+	Name  string
+	Attrs []slog.Attr
+}
+
+// LogValue implements the slog.LogValuer interface.
+func (e TraceEvent) LogValue() slog.Value {
+	return slog.GroupValue(append([]slog.Attr{
+		slog.String(TraceEventNameKey, e.Name),
+		slog.Time(TraceEventTimeKey, e.Time),
+	}, e.Attrs...)...)
 }
 
 // End ends the context trace and calculates the time elapsed since its starting.
+// The monotonic clock reading of the start time is used, if any.
 func (t *Trace) End() {
-	t.TimeElapsedMs = time.Since(t.StartTime).Milliseconds()
+	t.Duration = time.Since(t.StartTime)
+	t.TimeElapsedMs = t.Duration.Milliseconds()
+}
+
+// AddEvent adds to the span a timestamped event named name, with these attributes.
+// Like SetAttr and SetStatus, it's not safe for concurrent use.
+func (t *Trace) AddEvent(name string, attrs ...slog.Attr) {
+	t.Events = append(t.Events, TraceEvent{Time: time.Now(), Name: name, Attrs: attrs})
+}
+
+// SetAttr sets an attribute to the span, like `db.table`, replacing the previous one with the same key.
+func (t *Trace) SetAttr(key string, value any) {
+	a := slog.Any(key, value)
+	for k := range t.Attrs {
+		if t.Attrs[k].Key == key {
+			t.Attrs[k] = a
+			return
+		}
+	}
+	t.Attrs = append(t.Attrs, a)
+}
+
+// SetStatus sets the status of the span: in error with this error, ok if nil.
+func (t *Trace) SetStatus(err error) {
+	t.Err = err
+	if err != nil {
+		t.Status = TraceStatusError
+	} else {
+		t.Status = TraceStatusOK
+	}
+}
+
+// Elapsed returns the time elapsed as a floating-point number of this unit, like time.Microsecond.
+func (t *Trace) Elapsed(unit time.Duration) float64 {
+	if unit <= 0 {
+		unit = time.Second
+	}
+	return float64(t.Duration) / float64(unit)
 }
 
 // LogAttr returns the trace as a slog.Attr.
@@ -85,22 +385,130 @@ func (t *Trace) LogValue() slog.Value {
 	return slog.GroupValue(t.logAttrs()...)
 }
 
-func (t *Trace) logAttrs() []slog.Attr {
+// idAttr returns the trace identifiers only as a slog.Attr, without the span timing, attributes,
+// events and status, which belong to its final log line.
+func (t *Trace) idAttr() slog.Attr {
+	return slog.Group(TraceKey, t.idAttrs()...)
+}
+
+func (t *Trace) idAttrs() []slog.Attr {
 	res := []slog.Attr{
 		slog.String(TraceIDKey, t.ID),
 	}
 	if t.SpanID != "" {
 		res = append(res, slog.String(TraceSpanIDKey, t.SpanID))
 	}
+	if t.ParentSpanID != "" {
+		res = append(res, slog.String(TraceParentSpanIDKey, t.ParentSpanID))
+	}
+	return res
+}
+
+func (t *Trace) logAttrs() []slog.Attr {
+	res := t.idAttrs()
 	if !t.StartTime.IsZero() {
 		res = append(res, slog.Int64(TraceTimeElapsedKey, t.TimeElapsedMs))
+		if t.Duration > 0 {
+			res = append(res, durationAttr(t, DurationUnit))
+		}
+	}
+	if len(t.Attrs) > 0 {
+		res = append(res, slog.Group(TraceAttrsKey, t.Attrs...))
+	}
+	if len(t.Events) > 0 {
+		events := make([]slog.Attr, len(t.Events))
+		for k, e := range t.Events {
+			events[k] = slog.Attr{Key: strconv.Itoa(k), Value: e.LogValue()}
+		}
+		res = append(res, slog.Group(TraceEventsKey, events...))
+	}
+	if t.Status != "" {
+		res = append(res, slog.String(TraceStatusKey, t.Status))
+	}
+	if t.Err != nil {
+		res = append(res, slog.String(TraceErrorKey, t.Err.Error()))
 	}
 	return res
 }
 
-// NewContext creates a new trace context.Context to carry the trace identifier.
+// durationAttr returns the duration of the trace in this unit, named after it, like `duration_us`.
+func durationAttr(t *Trace, unit time.Duration) slog.Attr {
+	switch unit {
+	case time.Nanosecond:
+		return slog.Float64(TraceDurationKey+"_ns", t.Elapsed(unit))
+	case time.Microsecond:
+		return slog.Float64(TraceDurationKey+"_us", t.Elapsed(unit))
+	case time.Millisecond:
+		return slog.Float64(TraceDurationKey+"_ms", t.Elapsed(unit))
+	default:
+		return slog.Float64(TraceDurationKey+"_s", t.Elapsed(time.Second))
+	}
+}
+
+// NewContext creates a new trace context.Context to carry the trace as the current span.
 func (t *Trace) NewContext(ctx context.Context) context.Context {
-	return context.WithValue(ctx, ctxTraceID, t.ID)
+	return context.WithValue(ctx, ctxTrace, t)
+}
+
+// NewSpan creates a child span of the trace: the current span becomes its parent span.
+// The W3C trace state and flags are inherited.
+func (t *Trace) NewSpan() *Trace {
+	return &Trace{
+		ID:           t.ID,
+		SpanID:       newSpanID(),
+		ParentSpanID: t.SpanID,
+		State:        t.State,
+		Flags:        t.Flags,
+	}
+}
+
+// SetHTTPHeader sets the trace context in the HTTP header, using the X-Trace-Id header
+// and the W3C traceparent and tracestate headers if the identifiers are compatible.
+func (t *Trace) SetHTTPHeader(h http.Header) {
+	h.Set(TraceIDHTTPHeader, t.ID)
+	p := t.TraceParent()
+	if p == "" {
+		h.Del(TraceParentHTTPHeader)
+		h.Del(TraceStateHTTPHeader)
+		return
+	}
+	h.Set(TraceParentHTTPHeader, p)
+	if t.State != "" {
+		h.Set(TraceStateHTTPHeader, t.State)
+	} else {
+		h.Del(TraceStateHTTPHeader)
+	}
+}
+
+// TraceParent returns the trace as a W3C traceparent value.
+// The trace ID must be a 16-byte hexadecimal value, dashes of an UUID are ignored,
+// and the span ID must be a 8-byte hexadecimal value. Otherwise, it returns an empty string.
+func (t *Trace) TraceParent() string {
+	id := traceParentID(t.ID)
+	if !isHex(id, traceIDHexLen) || isZero(id) || !isHex(t.SpanID, spanIDHexLen) || isZero(t.SpanID) {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s-%s-%02x", traceParentVersion, id, t.SpanID, t.Flags)
+}
+
+// TimeElapsed starts the span and returns a function to end it and log its complete record:
+// identifiers, time elapsed, attributes, events and status.
+// Example:
+//
+//	t := logm.NewTraceFromContext(ctx)
+//	defer t.TimeElapsed(ctx, logger, slog.LevelDebug, "func")()
+//	t.SetAttr("cache.hit", true)
+func (t *Trace) TimeElapsed(ctx context.Context, l *slog.Logger, level slog.Level, msg string, attrs ...slog.Attr) func() {
+	t.Start()
+	return func() {
+		t.End()
+		l.LogAttrs(ctx, level, msg, append(attrs, t.LogAttr())...)
+	}
+}
+
+// traceParentID returns the trace ID as expected by the W3C traceparent: lowercase, without dashes.
+func traceParentID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
 }
 
 // Start adds a start time to the trace.
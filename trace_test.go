@@ -2,8 +2,11 @@ package logm_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,14 +18,33 @@ import (
 )
 
 const (
-	traceID = "7300cb05-8323-4dcc-8272-8d6a2c6b7fbc"
-	spanID  = "1d889d18-9159-4ff1-9397-fab540cbeb17"
+	traceID     = "7300cb05-8323-4dcc-8272-8d6a2c6b7fbc"
+	spanID      = "1d889d18-9159-4ff1-9397-fab540cbeb17"
+	w3cTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	w3cSpanID   = "00f067aa0ba902b7"
+	traceParent = "00-" + w3cTraceID + "-" + w3cSpanID + "-01"
+	traceState  = "congo=t61rcWkgMzE"
 )
 
 func TestNewTrace(t *testing.T) {
 	t.Parallel()
-	tc := logm.NewTrace()
-	is.New(t).True(tc.ID != "") // expected trace ID
+	var (
+		are = is.New(t)
+		tc  = logm.NewTrace()
+	)
+	are.True(tc.ID != "")            // expected trace ID
+	are.True(tc.SpanID != "")        // expected root span ID
+	are.True(tc.TraceParent() != "") // expected traceparent
+}
+
+func TestNewTraceWithIDGenerator(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		tc  = logm.NewTraceWithIDGenerator(logm.W3CGenerator)
+	)
+	are.Equal(32, len(tc.ID)) // mismatch W3C trace ID
+	are.True(tc.SpanID != "") // expected root span ID
 }
 
 func TestNewTraceFromContext(t *testing.T) {
@@ -41,6 +63,18 @@ func TestNewTraceFromContext(t *testing.T) {
 		t2 := logm.NewTraceFromContext(t1.NewContext(context.Background()))
 		is.New(t).Equal(traceID, t2.ID) // mismatch trace ID
 	})
+
+	t.Run("Child span", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are = is.New(t)
+			t1  = logm.Trace{ID: traceID, SpanID: w3cSpanID}
+			t2  = logm.NewTraceFromContext(t1.NewContext(context.Background()))
+		)
+		are.Equal(traceID, t2.ID)             // mismatch trace ID
+		are.Equal(w3cSpanID, t2.ParentSpanID) // mismatch parent span ID
+		are.True(t2.SpanID != w3cSpanID)      // expected new span ID
+	})
 }
 
 func TestNewTraceFromHTTPRequest(t *testing.T) {
@@ -65,6 +99,183 @@ func TestNewTraceFromHTTPRequest(t *testing.T) {
 		)
 		are.Equal(traceID, tc.ID) // mismatch trace ID
 	})
+
+	t.Run("W3C", func(t *testing.T) {
+		t.Parallel()
+		var (
+			req = &http.Request{
+				Header: map[string][]string{
+					logm.TraceIDHTTPHeader:     {traceID},
+					logm.TraceParentHTTPHeader: {traceParent},
+					logm.TraceStateHTTPHeader:  {traceState},
+				},
+			}
+			tc = logm.NewTraceFromHTTPRequest(req)
+		)
+		are.Equal(w3cTraceID, tc.ID)     // mismatch trace ID
+		are.True(tc.SpanID != w3cSpanID) // expected new span ID
+		are.Equal(byte(1), tc.Flags)     // mismatch flags
+		are.Equal(traceState, tc.State)  // mismatch state
+	})
+
+	t.Run("W3C with matching X-Trace-Id", func(t *testing.T) {
+		t.Parallel()
+		var (
+			id  = "4bf92f35-77b3-4da6-a3ce-929d0e0e4736"
+			req = &http.Request{
+				Header: map[string][]string{
+					logm.TraceIDHTTPHeader:     {id},
+					logm.TraceParentHTTPHeader: {traceParent},
+				},
+			}
+			tc = logm.NewTraceFromHTTPRequest(req)
+		)
+		are.Equal(id, tc.ID)                  // X-Trace-Id form expected
+		are.Equal(w3cSpanID, tc.ParentSpanID) // mismatch parent span ID
+	})
+
+	t.Run("Invalid W3C", func(t *testing.T) {
+		t.Parallel()
+		var (
+			req = &http.Request{
+				Header: map[string][]string{
+					logm.TraceIDHTTPHeader:     {traceID},
+					logm.TraceParentHTTPHeader: {"00-00000000000000000000000000000000-" + w3cSpanID + "-01"},
+				},
+			}
+			tc = logm.NewTraceFromHTTPRequest(req)
+		)
+		are.Equal(traceID, tc.ID) // mismatch trace ID
+	})
+}
+
+func TestTraceFromContext(t *testing.T) {
+	t.Parallel()
+	are := is.New(t)
+
+	t.Run("Undefined", func(t *testing.T) {
+		t.Parallel()
+		tc, ok := logm.TraceFromContext(context.Background())
+		are.True(!ok)      // unexpected trace
+		are.Equal(nil, tc) // unexpected trace
+	})
+
+	t.Run("Defined", func(t *testing.T) {
+		t.Parallel()
+		var (
+			t1     = logm.NewTraceSpan(traceID)
+			t2, ok = logm.TraceFromContext(t1.NewContext(context.Background()))
+		)
+		are.True(ok)      // expected trace
+		are.Equal(t1, t2) // mismatch span
+	})
+}
+
+func TestTraceFromHTTPRequest(t *testing.T) {
+	t.Parallel()
+	for desc, tc := range map[string]struct {
+		hdr http.Header
+		out *logm.Trace
+		ok  bool
+	}{
+		"Undefined": {},
+		"Blank":     {hdr: http.Header{logm.TraceIDHTTPHeader: {""}}},
+		"Default": {
+			hdr: http.Header{logm.TraceIDHTTPHeader: {traceID}},
+			out: &logm.Trace{ID: traceID},
+			ok:  true,
+		},
+		"W3C": {
+			hdr: http.Header{
+				logm.TraceIDHTTPHeader:     {traceID},
+				logm.TraceParentHTTPHeader: {traceParent},
+				logm.TraceStateHTTPHeader:  {traceState},
+			},
+			out: &logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID, State: traceState, Flags: 1},
+			ok:  true,
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			are := is.New(t)
+			out, ok := logm.TraceFromHTTPRequest(&http.Request{Header: tt.hdr})
+			are.Equal(tt.ok, ok)   // mismatch result
+			are.Equal(tt.out, out) // mismatch trace
+		})
+	}
+}
+
+func TestTraceFromHTTPRequest_state(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	members := func(prefix string, n int) []string {
+		res := make([]string, n)
+		for k := range res {
+			res[k] = fmt.Sprintf("%s%02d=%s", prefix, k, strings.Repeat("x", 16))
+		}
+		return res
+	}
+	for desc, tc := range map[string]struct {
+		in  []string
+		out string
+	}{
+		"Default":          {in: []string{traceState}, out: traceState},
+		"Multiple headers": {in: []string{"a=1", " b@c-d=2 ,,", "0t/*_-@s=3"}, out: "a=1,b@c-d=2,0t/*_-@s=3"},
+		"Invalid key":      {in: []string{"a=1,B=2"}},
+		"Invalid system":   {in: []string{"a@0=1"}},
+		"Invalid value":    {in: []string{"a=1=2"}},
+		"Missing value":    {in: []string{"a"}},
+		"Duplicated key":   {in: []string{"a=1", "a=2"}},
+		"Too many members": {in: members("k", 33)},
+		"Large member":     {in: append([]string{"big=" + strings.Repeat("x", 196)}, members("k", 20)...), out: strings.Join(members("k", 20), ",")},
+		"Too long":         {in: members("k", 30), out: strings.Join(members("k", 24), ",")},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			out, ok := logm.TraceFromHTTPRequest(&http.Request{Header: http.Header{
+				logm.TraceParentHTTPHeader: {traceParent},
+				logm.TraceStateHTTPHeader:  tt.in,
+			}})
+			are.True(ok)                    // expected trace
+			are.Equal(tt.out, out.State)    // mismatch state
+			are.True(len(out.State) <= 512) // state too long
+		})
+	}
+}
+
+func TestParseTraceParent(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for name, tc := range map[string]struct {
+		in  string
+		out *logm.Trace
+		err error
+	}{
+		"Default":          {err: logm.ErrInvalidTraceParent},
+		"OK":               {in: traceParent, out: &logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID, Flags: 1}},
+		"Not sampled":      {in: "00-" + w3cTraceID + "-" + w3cSpanID + "-00", out: &logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID}},
+		"Future version":   {in: "cc-" + w3cTraceID + "-" + w3cSpanID + "-01-what", out: &logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID, Flags: 1}},
+		"Invalid version":  {in: "ff-" + w3cTraceID + "-" + w3cSpanID + "-01", err: logm.ErrInvalidTraceParent},
+		"Extra field":      {in: traceParent + "-what", err: logm.ErrInvalidTraceParent},
+		"Uppercase":        {in: "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + w3cSpanID + "-01", err: logm.ErrInvalidTraceParent},
+		"Zero trace ID":    {in: "00-00000000000000000000000000000000-" + w3cSpanID + "-01", err: logm.ErrInvalidTraceParent},
+		"Zero parent ID":   {in: "00-" + w3cTraceID + "-0000000000000000-01", err: logm.ErrInvalidTraceParent},
+		"Invalid flags":    {in: "00-" + w3cTraceID + "-" + w3cSpanID + "-1", err: logm.ErrInvalidTraceParent},
+		"Invalid trace ID": {in: "00-" + traceID + "-" + w3cSpanID + "-01", err: logm.ErrInvalidTraceParent},
+	} {
+		tt := tc
+		t.Run(name, func(t *testing.T) {
+			out, err := logm.ParseTraceParent(tt.in)
+			are.True(errors.Is(err, tt.err))     // mismatch error
+			are.Equal("", cmp.Diff(tt.out, out)) // mismatch trace
+		})
+	}
 }
 
 func TestNewTraceSpan(t *testing.T) {
@@ -74,8 +285,9 @@ func TestNewTraceSpan(t *testing.T) {
 	t.Run("Default", func(t *testing.T) {
 		t.Parallel()
 		tc := logm.NewTraceSpan("")
-		are.True(tc.ID != "")     // expected trace ID
-		are.True(tc.SpanID == "") // unexpected trace span ID
+		are.True(tc.ID != "")           // expected trace ID
+		are.True(tc.SpanID != "")       // expected root span ID
+		are.True(tc.ParentSpanID == "") // unexpected parent span ID
 	})
 
 	t.Run("OK", func(t *testing.T) {
@@ -90,7 +302,38 @@ func TestTrace_End(t *testing.T) {
 	t.Parallel()
 	tc := logm.Trace{StartTime: time.Now().Add(-time.Second)}
 	tc.End()
-	is.New(t).Equal(time.Second.Milliseconds(), tc.TimeElapsedMs) // mismatch time elapsed
+	are := is.New(t)
+	are.Equal(time.Second.Milliseconds(), tc.TimeElapsedMs)  // mismatch time elapsed
+	are.True(tc.Duration >= time.Second)                     // mismatch duration
+	are.True(tc.Duration < time.Second+time.Millisecond*100) // mismatch duration
+}
+
+func TestTrace_AddEvent(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		tc  = logm.Trace{ID: traceID}
+	)
+	tc.AddEvent("cache.miss", slog.String("key", info))
+	tc.AddEvent("retry")
+	are.Equal(2, len(tc.Events))               // mismatch events
+	are.Equal("cache.miss", tc.Events[0].Name) // mismatch name
+	are.True(!tc.Events[0].Time.IsZero())      // missing time
+	are.Equal(1, len(tc.Events[0].Attrs))      // mismatch attributes
+	are.Equal("retry", tc.Events[1].Name)      // mismatch name
+}
+
+func TestTrace_Elapsed(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		tc  = logm.Trace{Duration: 1500 * time.Microsecond}
+	)
+	are.Equal(1500000.0, tc.Elapsed(time.Nanosecond)) // mismatch nanoseconds
+	are.Equal(1500.0, tc.Elapsed(time.Microsecond))   // mismatch microseconds
+	are.Equal(1.5, tc.Elapsed(time.Millisecond))      // mismatch milliseconds
+	are.Equal(0.0015, tc.Elapsed(time.Second))        // mismatch seconds
+	are.Equal(0.0015, tc.Elapsed(0))                  // seconds expected by default
 }
 
 func TestTrace_LogAttr(t *testing.T) {
@@ -111,6 +354,14 @@ func TestTrace_LogAttr(t *testing.T) {
 				slog.String(logm.TraceSpanIDKey, spanID),
 			},
 		},
+		"With parent span ID": {
+			in: logm.Trace{ID: traceID, SpanID: spanID, ParentSpanID: w3cSpanID},
+			out: []slog.Attr{
+				slog.String(logm.TraceIDKey, traceID),
+				slog.String(logm.TraceSpanIDKey, spanID),
+				slog.String(logm.TraceParentSpanIDKey, w3cSpanID),
+			},
+		},
 		"Unexpected time elapsed (missing start time)": {
 			in: logm.Trace{
 				TimeElapsedMs: math.MaxUint8,
@@ -135,6 +386,38 @@ func TestTrace_LogAttr(t *testing.T) {
 				slog.Int64(logm.TraceTimeElapsedKey, math.MaxUint8),
 			},
 		},
+		"With span": {
+			in: logm.Trace{
+				ID:     traceID,
+				Attrs:  []slog.Attr{slog.Bool("cache.hit", true)},
+				Events: []logm.TraceEvent{{Time: time.Unix(0, 0), Name: "retry"}},
+				Status: logm.TraceStatusError,
+				Err:    errors.New(intErr),
+			},
+			out: []slog.Attr{
+				slog.String(logm.TraceIDKey, traceID),
+				slog.Group(logm.TraceAttrsKey, slog.Bool("cache.hit", true)),
+				slog.Group(logm.TraceEventsKey, slog.Group("0",
+					slog.String(logm.TraceEventNameKey, "retry"),
+					slog.Time(logm.TraceEventTimeKey, time.Unix(0, 0)),
+				)),
+				slog.String(logm.TraceStatusKey, logm.TraceStatusError),
+				slog.String(logm.TraceErrorKey, intErr),
+			},
+		},
+		"With duration": {
+			in: logm.Trace{
+				StartTime:     time.Now(), // not used
+				Duration:      250 * time.Microsecond,
+				TimeElapsedMs: 0,
+				ID:            traceID,
+			},
+			out: []slog.Attr{
+				slog.String(logm.TraceIDKey, traceID),
+				slog.Int64(logm.TraceTimeElapsedKey, 0),
+				slog.Float64(logm.TraceDurationKey+"_ms", 0.25),
+			},
+		},
 	} {
 		tt := tc
 		t.Run(name, func(t *testing.T) {
@@ -156,6 +439,87 @@ func TestTrace_NewContext(t *testing.T) {
 	is.New(t).Equal(traceID, t2.ID) // mismatch trace ID
 }
 
+func TestTrace_NewSpan(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		t1  = logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID, ParentSpanID: spanID, State: traceState, Flags: 1}
+		t2  = t1.NewSpan()
+	)
+	are.Equal(w3cTraceID, t2.ID)          // mismatch trace ID
+	are.Equal(w3cSpanID, t2.ParentSpanID) // mismatch parent span ID
+	are.True(t2.SpanID != "")             // expected span ID
+	are.True(t2.SpanID != w3cSpanID)      // expected new span ID
+	are.Equal(traceState, t2.State)       // mismatch state
+	are.Equal(byte(1), t2.Flags)          // mismatch flags
+}
+
+func TestTrace_SetAttr(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		tc  = logm.Trace{ID: traceID}
+	)
+	tc.SetAttr("db.table", "users")
+	tc.SetAttr("cache.hit", false)
+	tc.SetAttr("cache.hit", true)
+	are.Equal("", cmp.Diff([]slog.Attr{
+		slog.String("db.table", "users"),
+		slog.Bool("cache.hit", true),
+	}, tc.Attrs)) // mismatch attributes
+}
+
+func TestTrace_SetHTTPHeader(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for name, tc := range map[string]struct {
+		in  logm.Trace
+		out http.Header
+	}{
+		"Default": {out: http.Header{logm.TraceIDHTTPHeader: {""}}},
+		"Custom":  {in: logm.Trace{ID: "myID", SpanID: w3cSpanID}, out: http.Header{logm.TraceIDHTTPHeader: {"myID"}}},
+		"UUID": {
+			in: logm.Trace{ID: traceID, SpanID: w3cSpanID},
+			out: http.Header{
+				logm.TraceIDHTTPHeader:     {traceID},
+				logm.TraceParentHTTPHeader: {"00-7300cb0583234dcc82728d6a2c6b7fbc-" + w3cSpanID + "-00"},
+			},
+		},
+		"Complete": {
+			in: logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID, State: traceState, Flags: 1},
+			out: http.Header{
+				logm.TraceIDHTTPHeader:     {w3cTraceID},
+				logm.TraceParentHTTPHeader: {traceParent},
+				logm.TraceStateHTTPHeader:  {traceState},
+			},
+		},
+	} {
+		tt := tc
+		t.Run(name, func(t *testing.T) {
+			h := http.Header{}
+			tt.in.SetHTTPHeader(h)
+			are.Equal("", cmp.Diff(tt.out, h)) // mismatch header
+		})
+	}
+}
+
+func TestTrace_SetStatus(t *testing.T) {
+	t.Parallel()
+	var (
+		are = is.New(t)
+		tc  = logm.Trace{ID: traceID}
+		err = errors.New(intErr)
+	)
+	tc.SetStatus(err)
+	are.Equal(logm.TraceStatusError, tc.Status) // mismatch error status
+	are.Equal(err, tc.Err)                      // mismatch error
+	tc.SetStatus(nil)
+	are.Equal(logm.TraceStatusOK, tc.Status) // mismatch ok status
+	are.NoErr(tc.Err)                        // unexpected error
+}
+
 func TestTrace_Start(t *testing.T) {
 	t.Parallel()
 	tc := logm.Trace{}
@@ -0,0 +1,158 @@
+package logm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// Transport is an HTTP client middleware designed to propagate the trace context of the request
+// to the outgoing request and to log every request and response.
+// Each outgoing request is a child span of the trace found in the request context.
+type Transport struct {
+	// Base is the http.RoundTripper used to make the HTTP request.
+	// If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Logger is used to log the request and the response. If nil, nothing is logged.
+	Logger *slog.Logger
+	// Redactor masks the sensitive query parameters. If nil, the DefaultRedactRules are applied.
+	Redactor *Redactor
+	// IDGenerator generates the identifiers of the new traces, without trace in the request context.
+	// If nil, the one defined by SetIDGenerator is used.
+	IDGenerator IDGenerator
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+// The response is logged once its body is read until EOF or closed, with the number of bytes read
+// and the time elapsed until then. Its level depends on the status code, see DefaultStatusLevel,
+// or is ERROR if the body can not be read.
+func (tr Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := newTraceFromContext(req.Context(), tr.IDGenerator)
+	// A RoundTripper should not modify the request.
+	r := req.Clone(req.Context())
+	t.SetHTTPHeader(r.Header)
+	t.Start()
+	resp, err := tr.base().RoundTrip(r)
+	if tr.Logger == nil {
+		return resp, err
+	}
+	if err != nil {
+		t.End()
+		tr.Logger.LogAttrs(r.Context(), slog.LevelError,
+			fmt.Sprintf("%s %s: %s", r.Method, logHTTPURL(r), err),
+			tr.logHTTPClientRequest(r),
+			t.LogAttr(),
+		)
+		return resp, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusSwitchingProtocols:
+		// The body is the connection, its size is unknown and its type must be kept: io.ReadWriteCloser.
+		tr.logHTTPClientResponse(r, resp, t, -1, nil)
+	case resp.Body == nil || resp.Body == http.NoBody:
+		tr.logHTTPClientResponse(r, resp, t, 0, nil)
+	default:
+		resp.Body = &responseBody{
+			ReadCloser: resp.Body,
+			done: func(size int64, err error) {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				tr.logHTTPClientResponse(r, resp, t, size, err)
+			},
+		}
+	}
+	return resp, nil
+}
+
+// logHTTPClientResponse logs the response, with its size if known, not negative,
+// and the error reading its body, if any.
+func (tr Transport) logHTTPClientResponse(r *http.Request, resp *http.Response, t *Trace, size int64, err error) {
+	t.End()
+	var (
+		level = DefaultStatusLevel(resp.StatusCode)
+		msg   = fmt.Sprintf("%d %s %s", resp.StatusCode, r.Method, logHTTPURL(r))
+		attrs = []slog.Attr{slog.Int(HTTPStatusKey, resp.StatusCode)}
+	)
+	if size >= 0 {
+		attrs = append(attrs, slog.Int64(HTTPSizeKey, size))
+	}
+	if err != nil {
+		t.SetStatus(err)
+		level = slog.LevelError
+		msg += ": " + err.Error()
+	}
+	tr.Logger.LogAttrs(r.Context(), level, msg,
+		tr.logHTTPClientRequest(r),
+		slog.Group(HTTPResponseKey, attrs...),
+		t.LogAttr(),
+	)
+}
+
+func (tr Transport) redactor() *Redactor {
+	if tr.Redactor == nil {
+		return defaultRedactor
+	}
+	return tr.Redactor
+}
+
+func (tr Transport) base() http.RoundTripper {
+	if tr.Base == nil {
+		return http.DefaultTransport
+	}
+	return tr.Base
+}
+
+func (tr Transport) logHTTPClientRequest(r *http.Request) slog.Attr {
+	return slog.Group(HTTPRequestKey,
+		slog.String(HTTPURLKey, logHTTPURL(r)),
+		slog.String(HTTPMethodKey, r.Method),
+		slog.String(HTTPQueryKey, tr.redactor().Query(r.URL.RawQuery)),
+	)
+}
+
+// logHTTPURL returns the request URL without its user information and query.
+func logHTTPURL(r *http.Request) string {
+	u := *r.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// responseBody wraps the body of a response to call done once it's read until an error, like io.EOF, or closed.
+type responseBody struct {
+	io.ReadCloser
+	size int64
+	once sync.Once
+	done func(size int64, err error)
+}
+
+// Read implements the io.Reader interface.
+func (b *responseBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if err != nil {
+		b.end(err)
+	}
+	return
+}
+
+// Close implements the io.Closer interface.
+func (b *responseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.end(nil)
+	return err
+}
+
+func (b *responseBody) end(err error) {
+	b.once.Do(func() {
+		b.done(b.size, err)
+	})
+}
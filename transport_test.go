@@ -0,0 +1,169 @@
+package logm_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/matryer/is"
+	"github.com/rvflash/logm"
+)
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	t.Run("OK", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are = is.New(t)
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.Header.Get(logm.TraceParentHTTPHeader)))
+			}))
+			buf = new(bytes.Buffer)
+			cli = &http.Client{Transport: logm.Transport{Logger: logm.DefaultLogger(name, buf)}}
+			tc  = logm.Trace{ID: w3cTraceID, SpanID: w3cSpanID, Flags: 1}
+		)
+		defer srv.Close()
+
+		req, err := http.NewRequestWithContext(tc.NewContext(context.Background()), http.MethodGet, srv.URL+"/?q=1&token="+secret, nil)
+		are.NoErr(err) // unexpected request error
+		res, err := cli.Do(req)
+		are.NoErr(err) // unexpected response error
+		defer func() { _ = res.Body.Close() }()
+		are.Equal("", buf.String()) // unexpected log before the end of the body
+
+		p, err := logm.ParseTraceParent(readAll(t, res))
+		are.NoErr(err)                                            // invalid traceparent
+		are.Equal(w3cTraceID, p.ID)                               // mismatch trace ID
+		are.True(p.SpanID != w3cSpanID)                           // expected client span
+		are.Equal("", req.Header.Get(logm.TraceParentHTTPHeader)) // unexpected request modification
+
+		out := buf.String()
+		are.True(strings.Contains(out, "200 GET "+srv.URL+"/"))            // message expected
+		are.True(strings.Contains(out, `req.query="q=1&token=%2A%2A%2A"`)) // request query redacted by default expected
+		are.True(!strings.Contains(out, secret))                           // unexpected secret
+		are.True(strings.Contains(out, "resp.status=200 resp.size=55"))    // response status and size expected
+		are.True(strings.Contains(out, "trace.id="+w3cTraceID))            // trace ID expected
+		are.True(strings.Contains(out, "trace.span_id="+p.SpanID))         // span ID expected
+		are.True(strings.Contains(out, "trace.parent_span_id="+w3cSpanID)) // parent span ID expected
+		are.True(strings.Contains(out, "trace.time_elapsed_ms="))          // time elapsed expected
+	})
+
+	t.Run("ID generator", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are = is.New(t)
+			buf = new(bytes.Buffer)
+			cli = &http.Client{Transport: logm.Transport{
+				Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+				}),
+				Logger: logm.DefaultLogger(name, buf),
+				IDGenerator: logm.IDGeneratorFunc(func() (string, error) {
+					return "fixed", nil
+				}),
+			}}
+		)
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		are.NoErr(err) // unexpected request error
+		res, err := cli.Do(req)
+		are.NoErr(err) // unexpected response error
+		_ = res.Body.Close()
+		are.True(strings.Contains(buf.String(), "trace.id=fixed")) // generated trace ID expected
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Parallel()
+		var (
+			are  = is.New(t)
+			oops = errors.New(intErr)
+			buf  = new(bytes.Buffer)
+			cli  = &http.Client{Transport: logm.Transport{
+				Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					return nil, oops
+				}),
+				Logger: logm.DefaultLogger(name, buf),
+			}}
+		)
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		are.NoErr(err)                 // unexpected request error
+		_, err = cli.Do(req)           //nolint:bodyclose
+		are.True(errors.Is(err, oops)) // mismatch error
+
+		out := buf.String()
+		are.True(strings.Contains(out, "level=ERROR")) // error level expected
+		are.True(strings.Contains(out, "GET "+target)) // message expected
+		are.True(strings.Contains(out, "trace.id="))   // trace ID expected
+	})
+}
+
+func TestTransport_RoundTrip_response(t *testing.T) {
+	t.Parallel()
+
+	are := is.New(t)
+
+	for desc, tc := range map[string]struct {
+		code int
+		body io.ReadCloser
+		read bool
+		out  string
+	}{
+		"No body":      {code: http.StatusNoContent, body: http.NoBody, out: "level=INFO msg=\"204 GET " + target + "\""},
+		"Server error": {code: http.StatusBadGateway, body: io.NopCloser(strings.NewReader(intErr)), read: true, out: "level=ERROR"},
+		"Not found":    {code: http.StatusNotFound, body: io.NopCloser(strings.NewReader(intErr)), read: true, out: "level=WARN"},
+		"Closed":       {code: http.StatusOK, body: io.NopCloser(strings.NewReader(intErr)), out: "resp.status=200 resp.size=0 "},
+		"Read":         {code: http.StatusOK, body: io.NopCloser(strings.NewReader(intErr)), read: true, out: "resp.status=200 resp.size=19 "},
+		"Read error": {
+			code: http.StatusOK,
+			body: io.NopCloser(iotest.ErrReader(errors.New(warn))),
+			read: true,
+			out:  `level=ERROR msg="200 GET ` + target + `: ` + warn + `"`,
+		},
+	} {
+		tt := tc
+		t.Run(desc, func(t *testing.T) {
+			t.Parallel()
+			var (
+				buf = new(bytes.Buffer)
+				cli = &http.Client{Transport: logm.Transport{
+					Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+						return &http.Response{StatusCode: tt.code, Body: tt.body, ContentLength: -1, Request: r}, nil
+					}),
+					Logger: logm.DefaultLogger(name, buf),
+				}}
+			)
+			req, err := http.NewRequest(http.MethodGet, target, nil)
+			are.NoErr(err) // unexpected request error
+			res, err := cli.Do(req)
+			are.NoErr(err) // unexpected response error
+			if tt.read {
+				_, _ = io.ReadAll(res.Body)
+			}
+			are.NoErr(res.Body.Close()) // unexpected close error
+			out := buf.String()
+			are.True(strings.Contains(out, tt.out))      // mismatch log
+			are.Equal(1, strings.Count(out, "\n"))       // expected one record
+			are.True(strings.Contains(out, "trace.id=")) // trace ID expected
+		})
+	}
+}
+
+func readAll(t *testing.T, res *http.Response) string {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	_, err := buf.ReadFrom(res.Body)
+	is.New(t).NoErr(err) // unexpected body error
+	return buf.String()
+}